@@ -3,40 +3,39 @@ package eventhandler
 import (
 	"fmt"
 
-	"github.com/bruno-beloff-aviva/event-core/cdk/dashboard"
-	"github.com/bruno-beloff-aviva/event-core/cdkstandards/sqs"
+	"github.com/bruno-beloff-aviva/event-core/cdk/queueconsumer"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awslambdaeventsources"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awspipes"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
-	awslambdago "github.com/aws/aws-cdk-go/awscdklambdagoalpha/v2"
-	"github.com/aws/aws-sdk-go/aws"
 )
 
-type EventHandlerCommonProps struct {
-	QueueKey        awskms.IKey
-	QueueMaxRetries int
-	MessageTable    awsdynamodb.ITable
-	Dashboard       dashboard.Dashboard
-}
+type EventHandlerCommonProps = queueconsumer.QueueConsumerCommonProps
 
 type EventHandlerBuilder struct {
 	QueueName   string
 	HandlerId   string
 	Entry       string
 	Environment map[string]*string
+	// If nil, no rule is created, and the queue must be wired to a bus by hand.
+	EventBus     awsevents.IEventBus
+	EventPattern awsevents.EventPattern
+	// e.g. $.detail.PolicyOrQuoteID - if empty, every event is in the message group QueueName.
+	MessageGroupIdPath string
+	HandlerProps       queueconsumer.HandlerProps
 }
 
+// EventHandlerConstruct is a FIFO QueueConsumerConstruct with an EventBridgeSource.
 type EventHandlerConstruct struct {
+	queueconsumer.QueueConsumerConstruct
 	Builder   EventHandlerBuilder
-	Queue     awssqs.Queue
-	Handler   awslambdago.GoFunction
-	Dashboard dashboard.Dashboard
+	Rule      awsevents.Rule
+	TargetDLQ awssqs.Queue
+	// only if MessageGroupIdPath is given
+	StagingQueue awssqs.Queue
+	Pipe         awspipes.CfnPipe
 }
 
 // ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
@@ -44,93 +43,54 @@ type EventHandlerConstruct struct {
 func (b EventHandlerBuilder) Setup(stack awscdk.Stack, commonProps EventHandlerCommonProps) EventHandlerConstruct {
 	var c EventHandlerConstruct
 
-	c.Builder = b
-	c.Dashboard = commonProps.Dashboard
-	c.Queue = b.setupQueue(stack, commonProps)
-
-	if b.HandlerId == "" {
-		return c
+	consumer := queueconsumer.QueueConsumerBuilder{
+		QueueName:    b.QueueName,
+		HandlerId:    b.HandlerId,
+		Entry:        b.Entry,
+		Environment:  b.Environment,
+		HandlerProps: b.HandlerProps,
+		Description:  "Handler with queue listening to EventBridge events",
+		Fifo:         true,
 	}
 
-	c.Handler = b.setupSubHandler(stack, c.Queue)
-	c.Queue.GrantConsumeMessages(c.Handler)
-	commonProps.MessageTable.GrantReadWriteData(c.Handler)
-
-	return c
-}
+	var source *queueconsumer.EventBridgeSource
 
-func (b EventHandlerBuilder) setupQueue(stack awscdk.Stack, commonProps EventHandlerCommonProps) awssqs.Queue {
-	queueProps := sqs.SqsQueueWithDLQProps{
-		Stack:                      stack,
-		QueueName:                  b.QueueName,
-		Fifo:                       true,
-		QContentBasedDeduplication: aws.Bool(true),
-		SQSKey:                     commonProps.QueueKey,
-		QMaxReceiveCount:           commonProps.QueueMaxRetries,
-		QAlarmPeriod:               1,
-		QAlarmThreshold:            1,
-		QAlarmEvaluationPeriod:     1,
-		DLQAlarmPeriod:             1,
-		DLQAlarmThreshold:          1,
-		DLQAlarmEvaluationPeriod:   1,
+	if b.EventBus != nil {
+		source = &queueconsumer.EventBridgeSource{
+			EventBus:           b.EventBus,
+			EventPattern:       b.EventPattern,
+			MessageGroupIdPath: b.MessageGroupIdPath,
+		}
+		consumer.Source = source
 	}
 
-	return sqs.NewSqsQueueWithDLQ(queueProps)
-}
+	c.QueueConsumerConstruct = consumer.Setup(stack, commonProps)
+	c.Builder = b
 
-func (b EventHandlerBuilder) setupSubHandler(stack awscdk.Stack, queue awssqs.IQueue) awslambdago.GoFunction {
-	handlerProps := awslambdago.GoFunctionProps{
-		Description:   aws.String("Handler with queue listening to EventBridge events"),
-		Runtime:       awslambda.Runtime_PROVIDED_AL2(),
-		Architecture:  awslambda.Architecture_ARM_64(),
-		Entry:         aws.String(b.Entry),
-		Timeout:       awscdk.Duration_Seconds(aws.Float64(28)),
-		LoggingFormat: awslambda.LoggingFormat_JSON,
-		LogRetention:  awslogs.RetentionDays_FIVE_DAYS,
-		Tracing:       awslambda.Tracing_ACTIVE,
-		Environment:   &b.Environment,
+	if source != nil {
+		c.Rule = source.Rule
+		c.TargetDLQ = source.TargetDLQ
+		c.StagingQueue = source.StagingQueue
+		c.Pipe = source.Pipe
 	}
 
-	handler := awslambdago.NewGoFunction(stack, aws.String(b.HandlerId), &handlerProps)
-
-	// TODO: use alias AFTER the project has been split, and deployments with / without alias can be tested
-
-	eventSourceProps := awslambdaeventsources.SqsEventSourceProps{}
-	handler.AddEventSource(awslambdaeventsources.NewSqsEventSource(queue, &eventSourceProps))
-
-	return handler
+	return c
 }
 
 // ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-func (c EventHandlerConstruct) LambdaMetricsGraphWidget() awscloudwatch.GraphWidget {
-	region := c.Handler.Stack().Region()
-
-	invocationsMetric := c.Dashboard.CreateLambdaMetric(*region, "Invocations", c.Handler.FunctionName(), "Sum")
-	errorsMetric := c.Dashboard.CreateLambdaMetric(*region, "Errors", c.Handler.FunctionName(), "Sum")
-	metrics := []awscloudwatch.IMetric{invocationsMetric, errorsMetric}
-
-	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%s - Invocations & Errors", c.Builder.HandlerId), metrics)
-}
-
-func (c EventHandlerConstruct) QueueMetricsGraphWidget() awscloudwatch.GraphWidget {
-	region := c.Queue.Stack().Region()
-	queueName := c.Queue.QueueName()
-
-	sentMetric := c.Dashboard.CreateQueueMetric(*region, "NumberOfMessagesSent", queueName, "Sum")
-	visibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesVisible", queueName, "Sum")
-	metrics := []awscloudwatch.IMetric{sentMetric, visibleMetric}
-
-	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%s - Sent & Visible", c.Builder.QueueName), metrics)
-}
+// TargetDLQMetricsGraphWidget returns nil if there is no target DLQ, as when the builder has no EventBus.
+func (c EventHandlerConstruct) TargetDLQMetricsGraphWidget() awscloudwatch.GraphWidget {
+	if c.TargetDLQ == nil {
+		return nil
+	}
 
-func (c EventHandlerConstruct) DLQMetricsGraphWidget() awscloudwatch.GraphWidget {
-	region := c.Queue.Stack().Region()
-	queueName := c.Queue.DeadLetterQueue().Queue.QueueName()
+	region := c.TargetDLQ.Stack().Region()
+	queueName := c.TargetDLQ.QueueName()
 
 	visibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesVisible", queueName, "Sum")
 	invisibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesNotVisible", queueName, "Sum")
 	metrics := []awscloudwatch.IMetric{visibleMetric, invisibleMetric}
 
-	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%sDLQ - Visible & Invisible", c.Builder.QueueName), metrics)
+	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%sTargetDLQ - Visible & Invisible", c.Builder.QueueName), metrics)
 }
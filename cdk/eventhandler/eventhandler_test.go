@@ -0,0 +1,138 @@
+package eventhandler
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+// the resources synthesised before EventHandlerBuilder became an adapter of QueueConsumerBuilder - changing their
+// logical IDs would replace them on deployment
+var expectedResources = []string{
+	"BusEA82B648 AWS::Events::EventBus",
+	"LogRetentionaae0aa3c5b4d4f87b02d85b201efdd8aFD4BFC8A AWS::Lambda::Function",
+	"LogRetentionaae0aa3c5b4d4f87b02d85b201efdd8aServiceRole9741ECFB AWS::IAM::Role",
+	"LogRetentionaae0aa3c5b4d4f87b02d85b201efdd8aServiceRoleDefaultPolicyADDA7DEB AWS::IAM::Policy",
+	"Messages804FA4EB AWS::DynamoDB::Table",
+	"OrdersA9B65338 AWS::SQS::Queue",
+	"OrdersAlarmD6392ABD AWS::CloudWatch::Alarm",
+	"OrdersDLQ8E8CD9EE AWS::SQS::Queue",
+	"OrdersDLQAlarm5571E2C0 AWS::CloudWatch::Alarm",
+	"OrdersHandlerA8584A8A AWS::Lambda::Function",
+	"OrdersHandlerLogRetentionBF4C61AD Custom::LogRetention",
+	"OrdersHandlerServiceRole858CF771 AWS::IAM::Role",
+	"OrdersHandlerServiceRoleDefaultPolicyB60DC6B6 AWS::IAM::Policy",
+	"OrdersHandlerSqsEventSourceTestStackOrders311A0410C337861A AWS::Lambda::EventSourceMapping",
+	"OrdersPolicy95F4027F AWS::SQS::QueuePolicy",
+	"OrdersRule251509FF AWS::Events::Rule",
+	"OrdersTargetDLQAlarmB70F0BB7 AWS::CloudWatch::Alarm",
+	"OrdersTargetDLQD0608146 AWS::SQS::Queue",
+	"OrdersTargetDLQPolicyF17F3D37 AWS::SQS::QueuePolicy",
+	"Policies05F61E66 AWS::SQS::Queue",
+	"PoliciesAlarm4432E422 AWS::CloudWatch::Alarm",
+	"PoliciesDLQAlarm3CF6E2EF AWS::CloudWatch::Alarm",
+	"PoliciesDLQF04DBBAD AWS::SQS::Queue",
+	"PoliciesHandlerE86D9895 AWS::Lambda::Function",
+	"PoliciesHandlerLogRetentionC40D5A51 Custom::LogRetention",
+	"PoliciesHandlerServiceRole01EBF9DF AWS::IAM::Role",
+	"PoliciesHandlerServiceRoleDefaultPolicy06EA745F AWS::IAM::Policy",
+	"PoliciesHandlerSqsEventSourceTestStackPolicies9980ECCE28E2DB36 AWS::Lambda::EventSourceMapping",
+	"PoliciesPipe AWS::Pipes::Pipe",
+	"PoliciesPipeRole23217465 AWS::IAM::Role",
+	"PoliciesPipeRoleDefaultPolicy96E8BFF9 AWS::IAM::Policy",
+	"PoliciesRuleEF51C291 AWS::Events::Rule",
+	"PoliciesStaging11266BD0 AWS::SQS::Queue",
+	"PoliciesStagingAlarmC9A8FDFF AWS::CloudWatch::Alarm",
+	"PoliciesStagingDLQ29AB270E AWS::SQS::Queue",
+	"PoliciesStagingDLQAlarm2ADA797E AWS::CloudWatch::Alarm",
+	"PoliciesStagingPolicy3FB3604F AWS::SQS::QueuePolicy",
+	"PoliciesTargetDLQAlarmDA2DB9A6 AWS::CloudWatch::Alarm",
+	"PoliciesTargetDLQEDAA220E AWS::SQS::Queue",
+	"PoliciesTargetDLQPolicy250EE670 AWS::SQS::QueuePolicy",
+	"QueueKey8E59F72C AWS::KMS::Key",
+}
+
+func TestEventHandlerBuilderResources(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, aws.String("TestStack"), nil)
+
+	commonProps := EventHandlerCommonProps{
+		QueueKey:        awskms.NewKey(stack, aws.String("QueueKey"), nil),
+		QueueMaxRetries: 3,
+		MessageTable: awsdynamodb.NewTable(stack, aws.String("Messages"), &awsdynamodb.TableProps{
+			PartitionKey: &awsdynamodb.Attribute{Name: aws.String("PK"), Type: awsdynamodb.AttributeType_STRING},
+		}),
+	}
+
+	bus := awsevents.NewEventBus(stack, aws.String("Bus"), nil)
+	pattern := awsevents.EventPattern{Source: &[]*string{aws.String("test-service")}}
+
+	EventHandlerBuilder{
+		QueueName:    "Orders",
+		HandlerId:    "OrdersHandler",
+		Entry:        "../queueconsumer/testdata/handler",
+		EventBus:     bus,
+		EventPattern: pattern,
+	}.Setup(stack, commonProps)
+
+	EventHandlerBuilder{
+		QueueName:          "Policies",
+		HandlerId:          "PoliciesHandler",
+		Entry:              "../queueconsumer/testdata/handler",
+		EventBus:           bus,
+		EventPattern:       pattern,
+		MessageGroupIdPath: "$.detail.PolicyOrQuoteID",
+	}.Setup(stack, commonProps)
+
+	template := assertions.Template_FromStack(stack, nil).ToJSON()
+
+	var resources []string
+
+	for id, resource := range (*template)["Resources"].(map[string]any) {
+		resources = append(resources, fmt.Sprintf("%s %s", id, resource.(map[string]any)["Type"]))
+	}
+
+	sort.Strings(resources)
+
+	assert.Equal(t, expectedResources, resources)
+}
+
+func TestTargetDLQMetricsGraphWidget(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, aws.String("TestStack"), nil)
+
+	commonProps := EventHandlerCommonProps{
+		QueueMaxRetries: 3,
+		MessageTable: awsdynamodb.NewTable(stack, aws.String("Messages"), &awsdynamodb.TableProps{
+			PartitionKey: &awsdynamodb.Attribute{Name: aws.String("PK"), Type: awsdynamodb.AttributeType_STRING},
+		}),
+	}
+
+	withBus := EventHandlerBuilder{
+		QueueName:    "Orders",
+		HandlerId:    "OrdersHandler",
+		Entry:        "../queueconsumer/testdata/handler",
+		EventBus:     awsevents.NewEventBus(stack, aws.String("Bus"), nil),
+		EventPattern: awsevents.EventPattern{Source: &[]*string{aws.String("test-service")}},
+	}.Setup(stack, commonProps)
+
+	assert.NotNil(t, withBus.TargetDLQMetricsGraphWidget())
+
+	// without a bus, there is no rule, so no target DLQ
+	withoutBus := EventHandlerBuilder{
+		QueueName: "Policies",
+		HandlerId: "PoliciesHandler",
+		Entry:     "../queueconsumer/testdata/handler",
+	}.Setup(stack, commonProps)
+
+	assert.Nil(t, withoutBus.TargetDLQ)
+	assert.Nil(t, withoutBus.TargetDLQMetricsGraphWidget())
+}
@@ -0,0 +1,422 @@
+// Package filterpolicy provides SNS subscription filter policies, and a local evaluator of their semantics so that
+// policies can be unit-tested.
+package filterpolicy
+
+// https://docs.aws.amazon.com/sns/latest/dg/sns-subscription-filter-policies.html
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Scope is the FilterPolicyScope of the subscription.
+type Scope string
+
+const (
+	MessageAttributes Scope = "MessageAttributes"
+	MessageBody       Scope = "MessageBody"
+)
+
+// SNS limits the number of keys in a policy.
+const maxKeys = 5
+
+// the comparisons of a numeric condition
+var numericOperators = []string{"=", "<", "<=", ">", ">="}
+
+// FilterPolicy - the zero value has no policy, so every message is delivered.
+type FilterPolicy struct {
+	Scope  Scope
+	Policy map[string]any
+}
+
+// Attributes is a policy on message attributes, e.g. {"EventType": ["PolicyIssued", "PolicyLapsed"]}.
+func Attributes(policy map[string]any) FilterPolicy {
+	return FilterPolicy{Scope: MessageAttributes, Policy: normalise(policy)}
+}
+
+// Body is a policy on the message body, which must be JSON - nested objects in the policy match nested objects in the
+// body, e.g. {"Payload": {"Product": [{"prefix": "motor"}]}}.
+func Body(policy map[string]any) FilterPolicy {
+	return FilterPolicy{Scope: MessageBody, Policy: normalise(policy)}
+}
+
+// Parse a policy given as JSON, e.g. copied from the console.
+func Parse(scope Scope, policyJSON string) (FilterPolicy, error) {
+	var policy map[string]any
+
+	err := json.Unmarshal([]byte(policyJSON), &policy)
+	if err != nil {
+		return FilterPolicy{}, fmt.Errorf("parse filter policy: %w", err)
+	}
+
+	p := FilterPolicy{Scope: scope, Policy: policy}
+
+	return p, p.Validate()
+}
+
+// normalise converts Go values, such as []string and int, to the types given by parsing JSON.
+func normalise(policy map[string]any) map[string]any {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return policy
+	}
+
+	var normalised map[string]any
+
+	_ = json.Unmarshal(policyJSON, &normalised)
+
+	return normalised
+}
+
+func (p FilterPolicy) IsEmpty() bool {
+	return len(p.Policy) == 0
+}
+
+func (p FilterPolicy) JSON() string {
+	policyJSON, _ := json.Marshal(p.Policy)
+
+	return string(policyJSON)
+}
+
+// Validate checks the structure of the policy and its operators - it does not check the SNS limit on the number of
+// value combinations.
+func (p FilterPolicy) Validate() error {
+	if p.Scope != MessageAttributes && p.Scope != MessageBody {
+		return fmt.Errorf("unknown filter policy scope: %q", p.Scope)
+	}
+
+	if p.Scope == MessageAttributes && containsNested(p.Policy) {
+		return fmt.Errorf("nested keys are only supported in %s scope", MessageBody)
+	}
+
+	if len(p.Policy) > maxKeys {
+		return fmt.Errorf("filter policy has %d keys - the maximum is %d", len(p.Policy), maxKeys)
+	}
+
+	return validateObject(p.Policy)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// MatchAttributes evaluates a MessageAttributes policy - attribute values are string, float64 (Number attributes) or
+// []any (String.Array attributes). An invalid policy matches nothing.
+func (p FilterPolicy) MatchAttributes(attributes map[string]any) bool {
+	if p.IsEmpty() {
+		return true
+	}
+
+	if p.Validate() != nil {
+		return false
+	}
+
+	return matchObject(p.Policy, attributes)
+}
+
+// MatchBody evaluates a MessageBody policy - a body that is not a JSON object matches only an empty policy. An invalid
+// policy matches nothing.
+func (p FilterPolicy) MatchBody(body string) bool {
+	if p.IsEmpty() {
+		return true
+	}
+
+	if p.Validate() != nil {
+		return false
+	}
+
+	var object map[string]any
+
+	err := json.Unmarshal([]byte(body), &object)
+	if err != nil {
+		return false
+	}
+
+	return matchObject(p.Policy, object)
+}
+
+// matchObject requires every key of the policy to match.
+func matchObject(policy map[string]any, object map[string]any) bool {
+	for key, condition := range policy {
+		if key == "$or" {
+			if !matchAny(condition.([]any), object) {
+				return false
+			}
+
+			continue
+		}
+
+		value, present := object[key]
+
+		switch c := condition.(type) {
+		case map[string]any:
+			nested, ok := value.(map[string]any)
+			if !ok || !matchObject(c, nested) {
+				return false
+			}
+
+		case []any:
+			if !matchConditions(c, value, present) {
+				return false
+			}
+
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchAny(policies []any, object map[string]any) bool {
+	for _, policy := range policies {
+		if matchObject(policy.(map[string]any), object) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchConditions requires any condition to match - if the value is an array, any of its elements.
+func matchConditions(conditions []any, value any, present bool) bool {
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+
+	for _, condition := range conditions {
+		if operator, ok := condition.(map[string]any); ok {
+			if exists, ok := operator["exists"].(bool); ok {
+				if exists == present {
+					return true
+				}
+
+				continue
+			}
+		}
+
+		if !present {
+			continue
+		}
+
+		for _, v := range values {
+			if matchCondition(condition, v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchCondition(condition any, value any) bool {
+	switch c := condition.(type) {
+	case nil:
+		return value == nil
+
+	case string, bool:
+		return c == value
+
+	case float64:
+		number, ok := value.(float64)
+		return ok && number == c
+
+	case map[string]any:
+		for operator, operand := range c {
+			return matchOperator(operator, operand, value)
+		}
+	}
+
+	return false
+}
+
+func matchOperator(operator string, operand any, value any) bool {
+	s, isString := value.(string)
+
+	switch operator {
+	case "prefix":
+		return isString && strings.HasPrefix(s, operand.(string))
+
+	case "suffix":
+		return isString && strings.HasSuffix(s, operand.(string))
+
+	case "equals-ignore-case":
+		return isString && strings.EqualFold(s, operand.(string))
+
+	case "anything-but":
+		switch o := operand.(type) {
+		case []any:
+			for _, excluded := range o {
+				if matchCondition(excluded, value) {
+					return false
+				}
+			}
+
+			return true
+
+		default:
+			return !matchCondition(o, value)
+		}
+
+	case "numeric":
+		number, ok := value.(float64)
+		return ok && matchNumeric(operand.([]any), number)
+	}
+
+	return false
+}
+
+// matchNumeric evaluates e.g. [">", 0, "<=", 150].
+func matchNumeric(comparisons []any, number float64) bool {
+	for i := 0; i+1 < len(comparisons); i += 2 {
+		operand := comparisons[i+1].(float64)
+
+		var ok bool
+
+		switch comparisons[i].(string) {
+		case "=":
+			ok = math.Abs(number-operand) < 1e-9
+		case "<":
+			ok = number < operand
+		case "<=":
+			ok = number <= operand
+		case ">":
+			ok = number > operand
+		case ">=":
+			ok = number >= operand
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// containsNested checks the alternatives of any $or, as well as the top level.
+func containsNested(policy map[string]any) bool {
+	for key, condition := range policy {
+		if _, ok := condition.(map[string]any); ok {
+			return true
+		}
+
+		alternatives, ok := condition.([]any)
+		if !ok || key != "$or" {
+			continue
+		}
+
+		for _, alternative := range alternatives {
+			if nested, ok := alternative.(map[string]any); ok && containsNested(nested) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func validateObject(policy map[string]any) error {
+	for key, condition := range policy {
+		switch c := condition.(type) {
+		case map[string]any:
+			err := validateObject(c)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+
+		case []any:
+			if len(c) == 0 {
+				return fmt.Errorf("%s: conditions must not be empty", key)
+			}
+
+			if key == "$or" {
+				for _, alternative := range c {
+					nested, ok := alternative.(map[string]any)
+					if !ok {
+						return fmt.Errorf("$or: alternatives must be objects")
+					}
+
+					err := validateObject(nested)
+					if err != nil {
+						return fmt.Errorf("$or: %w", err)
+					}
+				}
+
+				continue
+			}
+
+			for _, value := range c {
+				err := validateCondition(value)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+			}
+
+		default:
+			return fmt.Errorf("%s: conditions must be an array", key)
+		}
+	}
+
+	return nil
+}
+
+func validateCondition(condition any) error {
+	operator, ok := condition.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	if len(operator) != 1 {
+		return fmt.Errorf("an operator object must have exactly one key")
+	}
+
+	for name, operand := range operator {
+		switch name {
+		case "prefix", "suffix", "equals-ignore-case":
+			if _, ok := operand.(string); !ok {
+				return fmt.Errorf("%s requires a string", name)
+			}
+
+		case "exists":
+			if _, ok := operand.(bool); !ok {
+				return fmt.Errorf("exists requires a boolean")
+			}
+
+		case "anything-but":
+			if _, ok := operand.(map[string]any); ok {
+				return validateCondition(operand)
+			}
+
+		case "numeric":
+			comparisons, ok := operand.([]any)
+			if !ok || len(comparisons) == 0 || len(comparisons)%2 != 0 {
+				return fmt.Errorf("numeric requires operator and number pairs")
+			}
+
+			for i := 0; i < len(comparisons); i += 2 {
+				comparison, ok := comparisons[i].(string)
+				if !ok {
+					return fmt.Errorf("numeric requires operator and number pairs")
+				}
+
+				if !slices.Contains(numericOperators, comparison) {
+					return fmt.Errorf("unsupported numeric operator: %s", comparison)
+				}
+
+				if _, ok := comparisons[i+1].(float64); !ok {
+					return fmt.Errorf("numeric requires operator and number pairs")
+				}
+			}
+
+		default:
+			return fmt.Errorf("unsupported operator: %s", name)
+		}
+	}
+
+	return nil
+}
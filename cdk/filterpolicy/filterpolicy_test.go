@@ -0,0 +1,119 @@
+package filterpolicy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchAttributes(t *testing.T) {
+	policy := Attributes(map[string]any{
+		"EventType": []string{"PolicyIssued", "PolicyLapsed"},
+		"Premium":   []any{map[string]any{"numeric": []any{">", 0, "<=", 150}}},
+		"Channel":   []any{map[string]any{"anything-but": []string{"test", "internal"}}},
+	})
+	fmt.Println(policy.JSON())
+
+	assert.Nil(t, policy.Validate())
+
+	assert.True(t, policy.MatchAttributes(map[string]any{"EventType": "PolicyIssued", "Premium": 100.0, "Channel": "web"}))
+	assert.False(t, policy.MatchAttributes(map[string]any{"EventType": "QuoteIssued", "Premium": 100.0, "Channel": "web"}))
+	assert.False(t, policy.MatchAttributes(map[string]any{"EventType": "PolicyIssued", "Premium": 200.0, "Channel": "web"}))
+	assert.False(t, policy.MatchAttributes(map[string]any{"EventType": "PolicyIssued", "Premium": 100.0, "Channel": "test"}))
+	assert.False(t, policy.MatchAttributes(map[string]any{"EventType": "PolicyIssued", "Premium": 100.0}))
+}
+
+func TestMatchAttributeOperators(t *testing.T) {
+	policy, err := Parse(MessageAttributes, `{"Source": [{"prefix": "policy-"}, {"equals-ignore-case": "QUOTES"}], "Retry": [{"exists": false}]}`)
+	assert.Nil(t, err)
+
+	assert.True(t, policy.MatchAttributes(map[string]any{"Source": "policy-service"}))
+	assert.True(t, policy.MatchAttributes(map[string]any{"Source": "quotes"}))
+	assert.False(t, policy.MatchAttributes(map[string]any{"Source": "billing"}))
+	assert.False(t, policy.MatchAttributes(map[string]any{"Source": "policy-service", "Retry": "1"}))
+
+	// String.Array attributes match if any element matches
+	assert.True(t, policy.MatchAttributes(map[string]any{"Source": []any{"billing", "policy-admin"}}))
+}
+
+func TestMatchBody(t *testing.T) {
+	policy := Body(map[string]any{
+		"EventType": []string{"PolicyIssued"},
+		"Payload": map[string]any{
+			"Product": []any{map[string]any{"suffix": "-motor"}},
+		},
+		"$or": []any{
+			map[string]any{"PolicyOrQuoteID": []any{map[string]any{"prefix": "P"}}},
+			map[string]any{"SchemaVersion": []any{map[string]any{"numeric": []any{">=", 2}}}},
+		},
+	})
+
+	assert.Nil(t, policy.Validate())
+
+	assert.True(t, policy.MatchBody(`{"EventType":"PolicyIssued","SchemaVersion":1,"PolicyOrQuoteID":"P1","Payload":{"Product":"private-motor"}}`))
+	assert.True(t, policy.MatchBody(`{"EventType":"PolicyIssued","SchemaVersion":2,"PolicyOrQuoteID":"Q1","Payload":{"Product":"private-motor"}}`))
+	assert.False(t, policy.MatchBody(`{"EventType":"PolicyIssued","SchemaVersion":1,"PolicyOrQuoteID":"Q1","Payload":{"Product":"private-motor"}}`))
+	assert.False(t, policy.MatchBody(`{"EventType":"PolicyIssued","SchemaVersion":1,"PolicyOrQuoteID":"P1","Payload":{"Product":"home"}}`))
+	assert.False(t, policy.MatchBody(`not json`))
+
+	assert.True(t, FilterPolicy{}.MatchBody(`not json`))
+}
+
+func TestValidate(t *testing.T) {
+	_, err := Parse(MessageAttributes, `{"Payload": {"Product": ["motor"]}}`)
+	assert.NotNil(t, err)
+
+	_, err = Parse(MessageBody, `{"Product": [{"wildcard": "*motor"}]}`)
+	assert.NotNil(t, err)
+
+	_, err = Parse(MessageBody, `{"Premium": [{"numeric": [">", "0"]}]}`)
+	assert.NotNil(t, err)
+
+	_, err = Parse(MessageBody, `{"A": ["1"], "B": ["2"], "C": ["3"], "D": ["4"], "E": ["5"], "F": ["6"]}`)
+	assert.NotNil(t, err)
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		scope  Scope
+		policy string
+	}{
+		{"unknown numeric operator", MessageBody, `{"Premium": [{"numeric": ["!=", 0]}]}`},
+		{"unknown operator in a range", MessageAttributes, `{"Premium": [{"numeric": [">", 0, "=<", 100]}]}`},
+		{"empty conditions", MessageAttributes, `{"EventType": []}`},
+		{"empty nested conditions", MessageBody, `{"Payload": {"Product": []}}`},
+		{"empty $or", MessageBody, `{"$or": []}`},
+		{"nested object in $or", MessageAttributes, `{"$or": [{"EventType": ["PolicyIssued"]}, {"Payload": {"Product": ["motor"]}}]}`},
+		{"nested object in nested $or", MessageAttributes, `{"$or": [{"$or": [{"Payload": {"Product": ["motor"]}}]}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.scope, test.policy)
+			fmt.Println(err)
+
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestValidateAccepts(t *testing.T) {
+	tests := []struct {
+		name   string
+		scope  Scope
+		policy string
+	}{
+		{"numeric range", MessageAttributes, `{"Premium": [{"numeric": [">=", 0, "<", 100]}]}`},
+		{"$or of attributes", MessageAttributes, `{"$or": [{"EventType": ["PolicyIssued"]}, {"Product": ["motor"]}]}`},
+		{"nested object in $or", MessageBody, `{"$or": [{"EventType": ["PolicyIssued"]}, {"Payload": {"Product": ["motor"]}}]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.scope, test.policy)
+			assert.Nil(t, err)
+		})
+	}
+}
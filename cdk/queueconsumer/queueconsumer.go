@@ -0,0 +1,255 @@
+package queueconsumer
+
+import (
+	"fmt"
+
+	"github.com/bruno-beloff-aviva/event-core/cdk/dashboard"
+	"github.com/bruno-beloff-aviva/event-core/cdkstandards/sqs"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambdaeventsources"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
+	awslambdago "github.com/aws/aws-cdk-go/awscdklambdagoalpha/v2"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// queue visibility timeouts, in seconds - see HandlerProps.Timeout
+const (
+	visibilityTimeoutFactor  = 6
+	defaultVisibilityTimeout = 30
+	maxVisibilityTimeout     = 12 * 60 * 60
+)
+
+type QueueConsumerCommonProps struct {
+	QueueKey        awskms.IKey
+	QueueMaxRetries int
+	MessageTable    awsdynamodb.ITable
+	Dashboard       dashboard.Dashboard
+}
+
+// Source connects the consumer's queue to the producer of its messages - SNSSource, EventBridgeSource, DirectSource or
+// S3Source.
+type Source interface {
+	Bind(stack awscdk.Stack, commonProps QueueConsumerCommonProps, c QueueConsumerConstruct)
+}
+
+type QueueConsumerBuilder struct {
+	QueueName   string
+	HandlerId   string
+	Entry       string
+	Environment map[string]*string
+	Description string
+	// FIFO queues are deduplicated by content, unless the producer gives a MessageDeduplicationId.
+	Fifo bool
+	// If nil, the queue has no producers until they are granted permission.
+	Source       Source
+	HandlerProps HandlerProps
+}
+
+// HandlerProps tunes the handler and its SQS event source - zero values keep the defaults.
+type HandlerProps struct {
+	// default PROVIDED_AL2
+	Runtime awslambda.Runtime
+	// in MB - default 128
+	MemorySize int
+	// default 28 seconds - if given, the queue visibility timeout is six times as long, as AWS recommends, and at least
+	// the 30 second default
+	Timeout awscdk.Duration
+	// default unreserved
+	ReservedConcurrency int
+	// default FIVE_DAYS
+	LogRetention awslogs.RetentionDays
+	// default 10 - more than 10 requires MaxBatchingWindow, and is not available on FIFO queues
+	BatchSize int
+	// standard queues only
+	MaxBatchingWindow awscdk.Duration
+	// 2 - 1000, default unlimited
+	MaxConcurrency int
+	// the handler must return an events.SQSEventResponse listing the failed messages, and only those are retried - a
+	// handler that returns an empty response has every message deleted. The singleshot gateway does not build one, so
+	// the handler must do so itself.
+	ReportBatchItemFailures bool
+	// messages that match none of the patterns are deleted without invoking the handler, e.g.
+	// {"body": {"EventType": ["PolicyIssued"]}}
+	FilterPatterns []map[string]any
+}
+
+type QueueConsumerConstruct struct {
+	Builder   QueueConsumerBuilder
+	Queue     awssqs.Queue
+	Handler   awslambdago.GoFunction
+	Dashboard dashboard.Dashboard
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (b QueueConsumerBuilder) Setup(stack awscdk.Stack, commonProps QueueConsumerCommonProps) QueueConsumerConstruct {
+	var c QueueConsumerConstruct
+
+	c.Builder = b
+	c.Dashboard = commonProps.Dashboard
+	c.Queue = b.setupQueue(stack, commonProps)
+
+	if b.Source != nil {
+		b.Source.Bind(stack, commonProps, c)
+	}
+
+	if b.HandlerId == "" {
+		return c
+	}
+
+	c.Handler = b.setupSubHandler(stack, c.Queue)
+	c.Queue.GrantConsumeMessages(c.Handler)
+	commonProps.MessageTable.GrantReadWriteData(c.Handler)
+
+	return c
+}
+
+func (b QueueConsumerBuilder) setupQueue(stack awscdk.Stack, commonProps QueueConsumerCommonProps) awssqs.Queue {
+	props := queueProps(stack, b.QueueName, b.Fifo, commonProps)
+
+	if b.Fifo {
+		props.QContentBasedDeduplication = aws.Bool(true)
+	}
+
+	if b.HandlerProps.Timeout != nil {
+		visibilityTimeout := visibilityTimeoutFactor * *b.HandlerProps.Timeout.ToSeconds(nil)
+		props.QVisibilityTimeout = aws.Float64(min(max(visibilityTimeout, defaultVisibilityTimeout), maxVisibilityTimeout))
+	}
+
+	return sqs.NewSqsQueueWithDLQ(props)
+}
+
+// queueProps are those of every queue of a consumer, including those set up by its source.
+func queueProps(stack awscdk.Stack, queueName string, fifo bool, commonProps QueueConsumerCommonProps) sqs.SqsQueueWithDLQProps {
+	return sqs.SqsQueueWithDLQProps{
+		Stack:                    stack,
+		QueueName:                queueName,
+		Fifo:                     fifo,
+		SQSKey:                   commonProps.QueueKey,
+		QMaxReceiveCount:         commonProps.QueueMaxRetries,
+		QAlarmPeriod:             1,
+		QAlarmThreshold:          1,
+		QAlarmEvaluationPeriod:   1,
+		DLQAlarmPeriod:           1,
+		DLQAlarmThreshold:        1,
+		DLQAlarmEvaluationPeriod: 1,
+	}
+}
+
+func (b QueueConsumerBuilder) setupSubHandler(stack awscdk.Stack, queue awssqs.IQueue) awslambdago.GoFunction {
+	props := b.HandlerProps
+
+	if b.Fifo && (props.BatchSize > 10 || props.MaxBatchingWindow != nil) {
+		panic(fmt.Sprintf("%s: a FIFO queue takes a BatchSize of at most 10, and no MaxBatchingWindow", b.QueueName))
+	}
+
+	handlerProps := awslambdago.GoFunctionProps{
+		Description:   aws.String(b.Description),
+		Runtime:       awslambda.Runtime_PROVIDED_AL2(),
+		Architecture:  awslambda.Architecture_ARM_64(),
+		Entry:         aws.String(b.Entry),
+		Timeout:       awscdk.Duration_Seconds(aws.Float64(28)),
+		LoggingFormat: awslambda.LoggingFormat_JSON,
+		LogRetention:  awslogs.RetentionDays_FIVE_DAYS,
+		Tracing:       awslambda.Tracing_ACTIVE,
+		Environment:   &b.Environment,
+	}
+
+	if props.Runtime != nil {
+		handlerProps.Runtime = props.Runtime
+	}
+
+	if props.MemorySize > 0 {
+		handlerProps.MemorySize = aws.Float64(float64(props.MemorySize))
+	}
+
+	if props.Timeout != nil {
+		handlerProps.Timeout = props.Timeout
+	}
+
+	if props.ReservedConcurrency > 0 {
+		handlerProps.ReservedConcurrentExecutions = aws.Float64(float64(props.ReservedConcurrency))
+	}
+
+	if props.LogRetention != "" {
+		handlerProps.LogRetention = props.LogRetention
+	}
+
+	handler := awslambdago.NewGoFunction(stack, aws.String(b.HandlerId), &handlerProps)
+
+	// TODO: use alias AFTER the project has been split, and deployments with / without alias can be tested
+
+	handler.AddEventSource(awslambdaeventsources.NewSqsEventSource(queue, props.eventSourceProps()))
+
+	return handler
+}
+
+func (p HandlerProps) eventSourceProps() *awslambdaeventsources.SqsEventSourceProps {
+	eventSourceProps := awslambdaeventsources.SqsEventSourceProps{
+		MaxBatchingWindow: p.MaxBatchingWindow,
+	}
+
+	if p.BatchSize > 0 {
+		eventSourceProps.BatchSize = aws.Float64(float64(p.BatchSize))
+	}
+
+	if p.MaxConcurrency > 0 {
+		eventSourceProps.MaxConcurrency = aws.Float64(float64(p.MaxConcurrency))
+	}
+
+	if p.ReportBatchItemFailures {
+		eventSourceProps.ReportBatchItemFailures = aws.Bool(true)
+	}
+
+	if len(p.FilterPatterns) > 0 {
+		filters := make([]*map[string]any, len(p.FilterPatterns))
+
+		for i, pattern := range p.FilterPatterns {
+			filters[i] = awslambda.FilterCriteria_Filter(&pattern)
+		}
+
+		eventSourceProps.Filters = &filters
+	}
+
+	return &eventSourceProps
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (c QueueConsumerConstruct) LambdaMetricsGraphWidget() awscloudwatch.GraphWidget {
+	region := c.Handler.Stack().Region()
+
+	invocationsMetric := c.Dashboard.CreateLambdaMetric(*region, "Invocations", c.Handler.FunctionName(), "Sum")
+	errorsMetric := c.Dashboard.CreateLambdaMetric(*region, "Errors", c.Handler.FunctionName(), "Sum")
+	metrics := []awscloudwatch.IMetric{invocationsMetric, errorsMetric}
+
+	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%s - Invocations & Errors", c.Builder.HandlerId), metrics)
+}
+
+func (c QueueConsumerConstruct) QueueMetricsGraphWidget() awscloudwatch.GraphWidget {
+	region := c.Queue.Stack().Region()
+	queueName := c.Queue.QueueName()
+
+	sentMetric := c.Dashboard.CreateQueueMetric(*region, "NumberOfMessagesSent", queueName, "Sum")
+	visibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesVisible", queueName, "Sum")
+	metrics := []awscloudwatch.IMetric{sentMetric, visibleMetric}
+
+	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%s - Sent & Visible", c.Builder.QueueName), metrics)
+}
+
+func (c QueueConsumerConstruct) DLQMetricsGraphWidget() awscloudwatch.GraphWidget {
+	region := c.Queue.Stack().Region()
+	queueName := c.Queue.DeadLetterQueue().Queue.QueueName()
+
+	visibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesVisible", queueName, "Sum")
+	invisibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesNotVisible", queueName, "Sum")
+	metrics := []awscloudwatch.IMetric{visibleMetric, invisibleMetric}
+
+	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%sDLQ - Visible & Invisible", c.Builder.QueueName), metrics)
+}
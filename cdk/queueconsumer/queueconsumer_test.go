@@ -0,0 +1,141 @@
+package queueconsumer
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestHandlerTemplate(props HandlerProps) assertions.Template {
+	return newTestTemplate(QueueConsumerBuilder{
+		QueueName:    "Quotes",
+		HandlerId:    "QuotesHandler",
+		Entry:        "testdata/handler",
+		HandlerProps: props,
+	})
+}
+
+func newTestTemplate(builder QueueConsumerBuilder) assertions.Template {
+	stack := newTestStack()
+
+	commonProps := QueueConsumerCommonProps{
+		QueueMaxRetries: 3,
+		MessageTable: awsdynamodb.NewTable(stack, aws.String("Messages"), &awsdynamodb.TableProps{
+			PartitionKey: &awsdynamodb.Attribute{Name: aws.String("PK"), Type: awsdynamodb.AttributeType_STRING},
+		}),
+	}
+
+	builder.Setup(stack, commonProps)
+
+	return assertions.Template_FromStack(stack, nil)
+}
+
+func TestHandlerPropsDefaults(t *testing.T) {
+	template := newTestHandlerTemplate(HandlerProps{})
+
+	template.HasResourceProperties(aws.String("AWS::Lambda::Function"), map[string]any{
+		"Handler":                      "bootstrap",
+		"Runtime":                      "provided.al2",
+		"Architectures":                []any{"arm64"},
+		"Timeout":                      28,
+		"TracingConfig":                map[string]any{"Mode": "Active"},
+		"LoggingConfig":                map[string]any{"LogFormat": "JSON"},
+		"MemorySize":                   assertions.Match_Absent(),
+		"ReservedConcurrentExecutions": assertions.Match_Absent(),
+	})
+
+	template.HasResourceProperties(aws.String("Custom::LogRetention"), map[string]any{"RetentionInDays": 5})
+
+	template.HasResourceProperties(aws.String("AWS::Lambda::EventSourceMapping"), map[string]any{
+		"BatchSize":                      assertions.Match_Absent(),
+		"MaximumBatchingWindowInSeconds": assertions.Match_Absent(),
+		"ScalingConfig":                  assertions.Match_Absent(),
+		"FunctionResponseTypes":          assertions.Match_Absent(),
+		"FilterCriteria":                 assertions.Match_Absent(),
+	})
+
+	template.HasResourceProperties(aws.String("AWS::SQS::Queue"), map[string]any{
+		"VisibilityTimeout": 30,
+		"RedrivePolicy":     map[string]any{"deadLetterTargetArn": assertions.Match_AnyValue(), "maxReceiveCount": 3},
+	})
+}
+
+func TestHandlerPropsTimeout(t *testing.T) {
+	template := newTestHandlerTemplate(HandlerProps{Timeout: awscdk.Duration_Minutes(aws.Float64(1)), BatchSize: 5})
+
+	template.HasResourceProperties(aws.String("AWS::Lambda::Function"), map[string]any{"Timeout": 60})
+	template.HasResourceProperties(aws.String("AWS::Lambda::EventSourceMapping"), map[string]any{"BatchSize": 5})
+
+	// the visibility timeout follows the handler timeout
+	template.HasResourceProperties(aws.String("AWS::SQS::Queue"), map[string]any{
+		"VisibilityTimeout": 360,
+		"RedrivePolicy":     assertions.Match_AnyValue(),
+	})
+}
+
+func TestHandlerProps(t *testing.T) {
+	template := newTestHandlerTemplate(HandlerProps{
+		Runtime:                 awslambda.Runtime_PROVIDED_AL2023(),
+		MemorySize:              512,
+		ReservedConcurrency:     5,
+		LogRetention:            awslogs.RetentionDays_ONE_WEEK,
+		BatchSize:               100,
+		MaxBatchingWindow:       awscdk.Duration_Seconds(aws.Float64(5)),
+		MaxConcurrency:          4,
+		ReportBatchItemFailures: true,
+		FilterPatterns:          []map[string]any{{"body": map[string]any{"EventType": []any{"PolicyIssued"}}}},
+	})
+
+	template.HasResourceProperties(aws.String("AWS::Lambda::Function"), map[string]any{
+		"Runtime":                      "provided.al2023",
+		"MemorySize":                   512,
+		"ReservedConcurrentExecutions": 5,
+	})
+
+	template.HasResourceProperties(aws.String("Custom::LogRetention"), map[string]any{"RetentionInDays": 7})
+
+	template.HasResourceProperties(aws.String("AWS::Lambda::EventSourceMapping"), map[string]any{
+		"BatchSize":                      100,
+		"MaximumBatchingWindowInSeconds": 5,
+		"ScalingConfig":                  map[string]any{"MaximumConcurrency": 4},
+		"FunctionResponseTypes":          []any{"ReportBatchItemFailures"},
+		"FilterCriteria":                 map[string]any{"Filters": []any{map[string]any{"Pattern": `{"body":{"EventType":["PolicyIssued"]}}`}}},
+	})
+}
+
+func TestHandlerPropsVisibilityTimeoutLimits(t *testing.T) {
+	// six times a short timeout is less than the default
+	template := newTestHandlerTemplate(HandlerProps{Timeout: awscdk.Duration_Seconds(aws.Float64(2))})
+
+	template.HasResourceProperties(aws.String("AWS::SQS::Queue"), map[string]any{
+		"VisibilityTimeout": 30,
+		"RedrivePolicy":     assertions.Match_AnyValue(),
+	})
+
+	// Lambda timeouts are at most 15 minutes, so the maximum only applies to a queue without a handler
+	template = newTestTemplate(QueueConsumerBuilder{QueueName: "Quotes", HandlerProps: HandlerProps{Timeout: awscdk.Duration_Hours(aws.Float64(3))}})
+
+	template.HasResourceProperties(aws.String("AWS::SQS::Queue"), map[string]any{
+		"VisibilityTimeout": 43200,
+		"RedrivePolicy":     assertions.Match_AnyValue(),
+	})
+}
+
+func TestHandlerPropsFifo(t *testing.T) {
+	builder := QueueConsumerBuilder{QueueName: "Quotes", HandlerId: "QuotesHandler", Entry: "testdata/handler", Fifo: true}
+
+	builder.HandlerProps = HandlerProps{BatchSize: 10}
+	newTestTemplate(builder).HasResourceProperties(aws.String("AWS::Lambda::EventSourceMapping"), map[string]any{"BatchSize": 10})
+
+	builder.HandlerProps = HandlerProps{BatchSize: 11}
+	assert.Panics(t, func() { newTestTemplate(builder) })
+
+	builder.HandlerProps = HandlerProps{MaxBatchingWindow: awscdk.Duration_Seconds(aws.Float64(1))}
+	assert.Panics(t, func() { newTestTemplate(builder) })
+}
@@ -0,0 +1,199 @@
+package queueconsumer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bruno-beloff-aviva/event-core/cdk/filterpolicy"
+	"github.com/bruno-beloff-aviva/event-core/cdkstandards/sqs"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awspipes"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3notifications"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// SNSSource subscribes the queue to the topic, with raw message delivery. FIFO queues require a FIFO topic, e.g. from
+// cdkstandards/sns NewTopic.
+type SNSSource struct {
+	Topic awssns.Topic
+	// If empty, the queue receives every message published to the topic.
+	FilterPolicy filterpolicy.FilterPolicy
+	// set by Bind
+	Subscription awssns.Subscription
+}
+
+func (s *SNSSource) Bind(stack awscdk.Stack, commonProps QueueConsumerCommonProps, c QueueConsumerConstruct) {
+	if c.Builder.Fifo && !aws.BoolValue(s.Topic.Fifo()) {
+		panic(fmt.Sprintf("%s: a FIFO queue requires a FIFO topic", c.Builder.QueueName))
+	}
+
+	subProps := awssnssubscriptions.SqsSubscriptionProps{
+		RawMessageDelivery: aws.Bool(true),
+	}
+
+	s.Subscription = s.Topic.AddSubscription(awssnssubscriptions.NewSqsSubscription(c.Queue, &subProps))
+
+	if !s.FilterPolicy.IsEmpty() {
+		s.setupFilterPolicy(c.Builder.QueueName)
+	}
+}
+
+// setupFilterPolicy sets the policy JSON on the underlying CfnSubscription, as the typed SqsSubscriptionProps
+// policies cannot express every operator.
+func (s *SNSSource) setupFilterPolicy(queueName string) {
+	err := s.FilterPolicy.Validate()
+	if err != nil {
+		panic(fmt.Sprintf("%s: invalid filter policy: %s", queueName, err))
+	}
+
+	cfnSubscription := s.Subscription.Node().DefaultChild().(awssns.CfnSubscription)
+	cfnSubscription.SetFilterPolicy(s.FilterPolicy.Policy)
+	cfnSubscription.SetFilterPolicyScope(aws.String(string(s.FilterPolicy.Scope)))
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// EventBridgeSource routes the events matching the pattern to the queue. EventBridge targets only take a fixed message
+// group ID, so if MessageGroupIdPath is given, the rule targets a standard staging queue, and a pipe - which can take
+// the message group ID from the event - moves the events to the FIFO queue. Events that cannot be delivered go to the
+// target DLQ, which must be a standard queue.
+//
+// The pipe has no DLQ of its own: a message that it cannot send stays on the staging queue, and is retried until the
+// staging queue moves it to its DLQ after QueueMaxRetries receives. The pipe's input template <$.body> relies on
+// Pipes parsing the message body as JSON - as the rule sends it - to send the event, rather than the SQS record, and
+// to find the message group ID in it. A message group ID path that is missing from the event fails the message.
+type EventBridgeSource struct {
+	EventBus     awsevents.IEventBus
+	EventPattern awsevents.EventPattern
+	// FIFO only, e.g. $.detail.PolicyOrQuoteID. If empty, every event is in the single message group QueueName, so
+	// events are consumed one batch at a time, in order, however many handlers could run.
+	MessageGroupIdPath string
+	// set by Bind - StagingQueue and Pipe only if MessageGroupIdPath is given
+	Rule         awsevents.Rule
+	TargetDLQ    awssqs.Queue
+	StagingQueue awssqs.Queue
+	Pipe         awspipes.CfnPipe
+}
+
+func (s *EventBridgeSource) Bind(stack awscdk.Stack, commonProps QueueConsumerCommonProps, c QueueConsumerConstruct) {
+	queueName := c.Builder.QueueName
+
+	if s.MessageGroupIdPath != "" && !c.Builder.Fifo {
+		panic(fmt.Sprintf("%s: MessageGroupIdPath requires a FIFO queue", queueName))
+	}
+
+	s.TargetDLQ = sqs.NewDeadletterQueue(stack, queueName+"Target", sqs.DeadLetterQueueConfig{SQSKey: commonProps.QueueKey})
+
+	if key := s.TargetDLQ.EncryptionMasterKey(); key != nil {
+		key.GrantEncryptDecrypt(awsiam.NewServicePrincipal(aws.String("events.amazonaws.com"), nil))
+	}
+
+	ruleProps := awsevents.RuleProps{
+		EventBus:     s.EventBus,
+		EventPattern: &s.EventPattern,
+		Description:  aws.String("Routes events to " + queueName),
+	}
+
+	s.Rule = awsevents.NewRule(stack, aws.String(queueName+"Rule"), &ruleProps)
+
+	targetProps := awseventstargets.SqsQueueProps{
+		DeadLetterQueue: s.TargetDLQ,
+		RetryAttempts:   aws.Float64(185),
+		MaxEventAge:     awscdk.Duration_Hours(aws.Float64(24)),
+	}
+
+	if s.MessageGroupIdPath == "" {
+		if c.Builder.Fifo {
+			targetProps.MessageGroupId = aws.String(queueName)
+		}
+
+		s.Rule.AddTarget(awseventstargets.NewSqsQueue(c.Queue, &targetProps))
+
+		return
+	}
+
+	s.StagingQueue = sqs.NewSqsQueueWithDLQ(queueProps(stack, queueName+"Staging", false, commonProps))
+
+	s.Rule.AddTarget(awseventstargets.NewSqsQueue(s.StagingQueue, &targetProps))
+	s.Pipe = s.setupPipe(stack, queueName, s.StagingQueue, c.Queue)
+}
+
+// setupPipe moves the event in the body of each staging queue message to the FIFO queue, one message at a time.
+func (s *EventBridgeSource) setupPipe(stack awscdk.Stack, queueName string, source awssqs.Queue, target awssqs.Queue) awspipes.CfnPipe {
+	if !strings.HasPrefix(s.MessageGroupIdPath, "$.") {
+		panic(fmt.Sprintf("%s: MessageGroupIdPath must be a JSON path, e.g. $.detail.PolicyOrQuoteID", queueName))
+	}
+
+	role := awsiam.NewRole(stack, aws.String(queueName+"PipeRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(aws.String("pipes.amazonaws.com"), nil),
+	})
+
+	source.GrantConsumeMessages(role)
+	target.GrantSendMessages(role)
+
+	pipeProps := awspipes.CfnPipeProps{
+		RoleArn: role.RoleArn(),
+		Source:  source.QueueArn(),
+		Target:  target.QueueArn(),
+		SourceParameters: &awspipes.CfnPipe_PipeSourceParametersProperty{
+			SqsQueueParameters: &awspipes.CfnPipe_PipeSourceSqsQueueParametersProperty{
+				BatchSize: aws.Float64(1),
+			},
+		},
+		TargetParameters: &awspipes.CfnPipe_PipeTargetParametersProperty{
+			InputTemplate: aws.String("<$.body>"),
+			SqsQueueParameters: &awspipes.CfnPipe_PipeTargetSqsQueueParametersProperty{
+				MessageGroupId: aws.String("$.body." + strings.TrimPrefix(s.MessageGroupIdPath, "$.")),
+			},
+		},
+	}
+
+	return awspipes.NewCfnPipe(stack, aws.String(queueName+"Pipe"), &pipeProps)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// DirectSource grants the producers permission to send messages to the queue, e.g. with SQSManager.Send.
+type DirectSource struct {
+	Producers []awsiam.IGrantable
+}
+
+func (s *DirectSource) Bind(stack awscdk.Stack, commonProps QueueConsumerCommonProps, c QueueConsumerConstruct) {
+	for _, producer := range s.Producers {
+		c.Queue.GrantSendMessages(producer)
+	}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// S3Source sends the bucket's event notifications to the queue - S3 cannot notify FIFO queues. The bucket may be
+// imported, e.g. with awss3.Bucket_FromBucketName.
+type S3Source struct {
+	Bucket awss3.IBucket
+	// If empty, objects created.
+	Events  []awss3.EventType
+	Filters []*awss3.NotificationKeyFilter
+}
+
+func (s *S3Source) Bind(stack awscdk.Stack, commonProps QueueConsumerCommonProps, c QueueConsumerConstruct) {
+	if c.Builder.Fifo {
+		panic(fmt.Sprintf("%s: S3 cannot notify a FIFO queue", c.Builder.QueueName))
+	}
+
+	events := s.Events
+	if len(events) == 0 {
+		events = []awss3.EventType{awss3.EventType_OBJECT_CREATED}
+	}
+
+	for _, event := range events {
+		s.Bucket.AddEventNotification(event, awss3notifications.NewSqsDestination(c.Queue), s.Filters...)
+	}
+}
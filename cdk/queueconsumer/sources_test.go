@@ -0,0 +1,153 @@
+package queueconsumer
+
+import (
+	"testing"
+
+	"github.com/bruno-beloff-aviva/event-core/cdk/filterpolicy"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func newTestStack() awscdk.Stack {
+	app := awscdk.NewApp(nil)
+
+	return awscdk.NewStack(app, aws.String("TestStack"), nil)
+}
+
+func TestSNSSource(t *testing.T) {
+	stack := newTestStack()
+	source := &SNSSource{
+		Topic:        awssns.NewTopic(stack, aws.String("Topic"), nil),
+		FilterPolicy: filterpolicy.Attributes(map[string]any{"EventType": []any{"PolicyIssued"}}),
+	}
+
+	QueueConsumerBuilder{QueueName: "Quotes", Source: source}.Setup(stack, QueueConsumerCommonProps{QueueMaxRetries: 3})
+
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.HasResourceProperties(aws.String("AWS::SNS::Subscription"), map[string]any{
+		"Protocol":           "sqs",
+		"RawMessageDelivery": true,
+		"FilterPolicy":       map[string]any{"EventType": []any{"PolicyIssued"}},
+		"FilterPolicyScope":  "MessageAttributes",
+	})
+}
+
+func TestDirectSource(t *testing.T) {
+	stack := newTestStack()
+	producer := awsiam.NewRole(stack, aws.String("Producer"), &awsiam.RoleProps{AssumedBy: awsiam.NewAccountRootPrincipal()})
+	source := &DirectSource{Producers: []awsiam.IGrantable{producer}}
+
+	QueueConsumerBuilder{QueueName: "Quotes", Source: source}.Setup(stack, QueueConsumerCommonProps{QueueMaxRetries: 3})
+
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.HasResourceProperties(aws.String("AWS::IAM::Policy"), map[string]any{
+		"PolicyDocument": map[string]any{"Statement": assertions.Match_ArrayWith(&[]any{assertions.Match_ObjectLike(&map[string]any{
+			"Action": assertions.Match_ArrayWith(&[]any{"sqs:SendMessage"}),
+		})})},
+		"Roles": []any{map[string]any{"Ref": assertions.Match_StringLikeRegexp(aws.String("^Producer"))}},
+	})
+}
+
+func TestS3Source(t *testing.T) {
+	stack := newTestStack()
+	source := &S3Source{Bucket: awss3.Bucket_FromBucketName(stack, aws.String("Bucket"), aws.String("imported-bucket"))}
+
+	QueueConsumerBuilder{QueueName: "Uploads", Source: source}.Setup(stack, QueueConsumerCommonProps{QueueMaxRetries: 3})
+
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.HasResourceProperties(aws.String("Custom::S3BucketNotifications"), map[string]any{
+		"BucketName": "imported-bucket",
+		"NotificationConfiguration": map[string]any{"QueueConfigurations": []any{assertions.Match_ObjectLike(&map[string]any{
+			"Events": []any{"s3:ObjectCreated:*"},
+		})}},
+	})
+
+	template.HasResourceProperties(aws.String("AWS::SQS::QueuePolicy"), map[string]any{
+		"PolicyDocument": map[string]any{"Statement": assertions.Match_ArrayWith(&[]any{assertions.Match_ObjectLike(&map[string]any{
+			"Principal": map[string]any{"Service": "s3.amazonaws.com"},
+		})})},
+	})
+}
+
+func newTestEventBridgeSource(stack awscdk.Stack, messageGroupIdPath string) *EventBridgeSource {
+	return &EventBridgeSource{
+		EventBus:           awsevents.NewEventBus(stack, aws.String("Bus"), nil),
+		EventPattern:       awsevents.EventPattern{Source: &[]*string{aws.String("test-service")}},
+		MessageGroupIdPath: messageGroupIdPath,
+	}
+}
+
+func TestEventBridgeSource(t *testing.T) {
+	stack := newTestStack()
+	source := newTestEventBridgeSource(stack, "")
+
+	QueueConsumerBuilder{QueueName: "Orders", Fifo: true, Source: source}.Setup(stack, QueueConsumerCommonProps{QueueMaxRetries: 3})
+
+	template := assertions.Template_FromStack(stack, nil)
+
+	// the queue, its DLQ and the target DLQ
+	template.ResourceCountIs(aws.String("AWS::SQS::Queue"), aws.Float64(3))
+	template.ResourceCountIs(aws.String("AWS::Pipes::Pipe"), aws.Float64(0))
+
+	template.HasResourceProperties(aws.String("AWS::Events::Rule"), map[string]any{
+		"EventPattern": map[string]any{"source": []any{"test-service"}},
+		"Targets": []any{assertions.Match_ObjectLike(&map[string]any{
+			"SqsParameters":    map[string]any{"MessageGroupId": "Orders"},
+			"DeadLetterConfig": assertions.Match_ObjectLike(&map[string]any{}),
+			"RetryPolicy":      map[string]any{"MaximumEventAgeInSeconds": 86400, "MaximumRetryAttempts": 185},
+		})},
+	})
+}
+
+func TestEventBridgeSourceMessageGroupIdPath(t *testing.T) {
+	stack := newTestStack()
+	source := newTestEventBridgeSource(stack, "$.detail.PolicyOrQuoteID")
+	key := awskms.NewKey(stack, aws.String("QueueKey"), nil)
+
+	QueueConsumerBuilder{QueueName: "Orders", Fifo: true, Source: source}.Setup(stack, QueueConsumerCommonProps{QueueKey: key, QueueMaxRetries: 3})
+
+	template := assertions.Template_FromStack(stack, nil)
+
+	// the queue and the staging queue, their DLQs, and the target DLQ
+	template.ResourceCountIs(aws.String("AWS::SQS::Queue"), aws.Float64(5))
+
+	template.HasResourceProperties(aws.String("AWS::Pipes::Pipe"), map[string]any{
+		"SourceParameters": map[string]any{"SqsQueueParameters": map[string]any{"BatchSize": 1}},
+		"TargetParameters": map[string]any{
+			"InputTemplate":      "<$.body>",
+			"SqsQueueParameters": map[string]any{"MessageGroupId": "$.body.detail.PolicyOrQuoteID"},
+		},
+	})
+
+	// the staging queue retries messages that the pipe cannot send, then moves them to its DLQ
+	template.HasResourceProperties(aws.String("AWS::SQS::Queue"), map[string]any{
+		"FifoQueue":     assertions.Match_Absent(),
+		"RedrivePolicy": map[string]any{"deadLetterTargetArn": assertions.Match_AnyValue(), "maxReceiveCount": 3},
+	})
+
+	// EventBridge can write to the target DLQ
+	template.HasResourceProperties(aws.String("AWS::KMS::Key"), map[string]any{
+		"KeyPolicy": map[string]any{"Statement": assertions.Match_ArrayWith(&[]any{assertions.Match_ObjectLike(&map[string]any{
+			"Principal": map[string]any{"Service": "events.amazonaws.com"},
+		})})},
+	})
+}
+
+func TestEventBridgeSourceWithoutQueueKey(t *testing.T) {
+	stack := newTestStack()
+	source := newTestEventBridgeSource(stack, "")
+
+	QueueConsumerBuilder{QueueName: "Orders", Fifo: true, Source: source}.Setup(stack, QueueConsumerCommonProps{QueueMaxRetries: 3})
+
+	assertions.Template_FromStack(stack, nil).ResourceCountIs(aws.String("AWS::Events::Rule"), aws.Float64(1))
+}
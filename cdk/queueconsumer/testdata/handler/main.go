@@ -0,0 +1,4 @@
+// Package main is the handler entry of the CDK tests, which only need it to build.
+package main
+
+func main() {}
@@ -0,0 +1,71 @@
+package snshandler
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+// the resources synthesised before SNSBuilder became an adapter of QueueConsumerBuilder - changing their logical IDs
+// would replace them on deployment
+var expectedResources = []string{
+	"LogRetentionaae0aa3c5b4d4f87b02d85b201efdd8aFD4BFC8A AWS::Lambda::Function",
+	"LogRetentionaae0aa3c5b4d4f87b02d85b201efdd8aServiceRole9741ECFB AWS::IAM::Role",
+	"LogRetentionaae0aa3c5b4d4f87b02d85b201efdd8aServiceRoleDefaultPolicyADDA7DEB AWS::IAM::Policy",
+	"Messages804FA4EB AWS::DynamoDB::Table",
+	"QueueKey8E59F72C AWS::KMS::Key",
+	"Quotes4DCFF1CF AWS::SQS::Queue",
+	"QuotesAlarm44FB65E5 AWS::CloudWatch::Alarm",
+	"QuotesDLQAlarmA1D9DD32 AWS::CloudWatch::Alarm",
+	"QuotesDLQD0E0A8ED AWS::SQS::Queue",
+	"QuotesHandler0BF0FD89 AWS::Lambda::Function",
+	"QuotesHandlerLogRetention4EFD1206 Custom::LogRetention",
+	"QuotesHandlerServiceRole1FBAB310 AWS::IAM::Role",
+	"QuotesHandlerServiceRoleDefaultPolicy4CAD16A8 AWS::IAM::Policy",
+	"QuotesHandlerSqsEventSourceTestStackQuotes7DB08B94448C7B5D AWS::Lambda::EventSourceMapping",
+	"QuotesPolicyB475FA6F AWS::SQS::QueuePolicy",
+	"QuotesTestStackTopic7490E7B960CB8CA1 AWS::SNS::Subscription",
+	"TopicBFC7AF6E AWS::SNS::Topic",
+}
+
+func TestSNSBuilderResources(t *testing.T) {
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, aws.String("TestStack"), nil)
+
+	commonProps := SNSCommonProps{
+		QueueKey:        awskms.NewKey(stack, aws.String("QueueKey"), nil),
+		QueueMaxRetries: 3,
+		MessageTable: awsdynamodb.NewTable(stack, aws.String("Messages"), &awsdynamodb.TableProps{
+			PartitionKey: &awsdynamodb.Attribute{Name: aws.String("PK"), Type: awsdynamodb.AttributeType_STRING},
+		}),
+	}
+
+	builder := SNSBuilder{
+		SubscriptionTopic: awssns.NewTopic(stack, aws.String("Topic"), nil),
+		QueueName:         "Quotes",
+		HandlerId:         "QuotesHandler",
+		Entry:             "../queueconsumer/testdata/handler",
+	}
+
+	builder.Setup(stack, commonProps)
+
+	template := assertions.Template_FromStack(stack, nil).ToJSON()
+
+	var resources []string
+
+	for id, resource := range (*template)["Resources"].(map[string]any) {
+		resources = append(resources, fmt.Sprintf("%s %s", id, resource.(map[string]any)["Type"]))
+	}
+
+	sort.Strings(resources)
+
+	assert.Equal(t, expectedResources, resources)
+}
@@ -0,0 +1,151 @@
+package streamhandler
+
+// https://docs.aws.amazon.com/lambda/latest/dg/with-ddb.html
+// https://docs.aws.amazon.com/lambda/latest/dg/invocation-eventfiltering.html#filtering-ddb
+
+import (
+	"fmt"
+
+	"github.com/bruno-beloff-aviva/event-core/cdk/dashboard"
+	"github.com/bruno-beloff-aviva/event-core/cdkstandards/sqs"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambdaeventsources"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslogs"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
+	awslambdago "github.com/aws/aws-cdk-go/awscdklambdagoalpha/v2"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+type StreamCommonProps struct {
+	QueueKey     awskms.IKey
+	MessageTable awsdynamodb.ITable
+	Dashboard    dashboard.Dashboard
+}
+
+type StreamHandlerBuilder struct {
+	SourceTable        awsdynamodb.ITable
+	HandlerId          string
+	Entry              string
+	Environment        map[string]*string
+	StartingPosition   awslambda.StartingPosition
+	BatchSize          int
+	BisectBatchOnError bool
+	// If 0, the default - records are retried until they expire.
+	RetryAttempts int
+	// Lambda event filter patterns, e.g. {"eventName": ["INSERT", "MODIFY"]} - any one must match
+	Filters []map[string]any
+}
+
+type StreamHandlerConstruct struct {
+	Builder   StreamHandlerBuilder
+	DLQ       awssqs.Queue
+	Handler   awslambdago.GoFunction
+	Dashboard dashboard.Dashboard
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (b StreamHandlerBuilder) Setup(stack awscdk.Stack, commonProps StreamCommonProps) StreamHandlerConstruct {
+	var c StreamHandlerConstruct
+
+	c.Builder = b
+	c.Dashboard = commonProps.Dashboard
+	c.DLQ = sqs.NewDeadletterQueue(stack, b.HandlerId, sqs.DeadLetterQueueConfig{SQSKey: commonProps.QueueKey})
+	c.Handler = b.setupStreamHandler(stack, c.DLQ)
+
+	if commonProps.MessageTable != nil {
+		commonProps.MessageTable.GrantReadWriteData(c.Handler)
+	}
+
+	return c
+}
+
+func (b StreamHandlerBuilder) setupStreamHandler(stack awscdk.Stack, dlq awssqs.IQueue) awslambdago.GoFunction {
+	handlerProps := awslambdago.GoFunctionProps{
+		Description:   aws.String("Handler listening to DynamoDB stream events"),
+		Runtime:       awslambda.Runtime_PROVIDED_AL2(),
+		Architecture:  awslambda.Architecture_ARM_64(),
+		Entry:         aws.String(b.Entry),
+		Timeout:       awscdk.Duration_Seconds(aws.Float64(28)),
+		LoggingFormat: awslambda.LoggingFormat_JSON,
+		LogRetention:  awslogs.RetentionDays_FIVE_DAYS,
+		Tracing:       awslambda.Tracing_ACTIVE,
+		Environment:   &b.Environment,
+	}
+
+	handler := awslambdago.NewGoFunction(stack, aws.String(b.HandlerId), &handlerProps)
+
+	handler.AddEventSource(awslambdaeventsources.NewDynamoEventSource(b.SourceTable, b.eventSourceProps(dlq)))
+
+	return handler
+}
+
+func (b StreamHandlerBuilder) eventSourceProps(dlq awssqs.IQueue) *awslambdaeventsources.DynamoEventSourceProps {
+	startingPosition := b.StartingPosition
+	if startingPosition == "" {
+		startingPosition = awslambda.StartingPosition_LATEST
+	}
+
+	eventSourceProps := awslambdaeventsources.DynamoEventSourceProps{
+		StartingPosition:   startingPosition,
+		BisectBatchOnError: aws.Bool(b.BisectBatchOnError),
+		OnFailure:          awslambdaeventsources.NewSqsDlq(dlq),
+	}
+
+	if b.RetryAttempts > 0 {
+		eventSourceProps.RetryAttempts = aws.Float64(float64(b.RetryAttempts))
+	}
+
+	if b.BatchSize > 0 {
+		eventSourceProps.BatchSize = aws.Float64(float64(b.BatchSize))
+	}
+
+	if len(b.Filters) > 0 {
+		filters := make([]*map[string]any, len(b.Filters))
+
+		for i := range b.Filters {
+			filters[i] = awslambda.FilterCriteria_Filter(&b.Filters[i])
+		}
+
+		eventSourceProps.Filters = &filters
+	}
+
+	return &eventSourceProps
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (c StreamHandlerConstruct) LambdaMetricsGraphWidget() awscloudwatch.GraphWidget {
+	region := c.Handler.Stack().Region()
+
+	invocationsMetric := c.Dashboard.CreateLambdaMetric(*region, "Invocations", c.Handler.FunctionName(), "Sum")
+	errorsMetric := c.Dashboard.CreateLambdaMetric(*region, "Errors", c.Handler.FunctionName(), "Sum")
+	metrics := []awscloudwatch.IMetric{invocationsMetric, errorsMetric}
+
+	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%s - Invocations & Errors", c.Builder.HandlerId), metrics)
+}
+
+func (c StreamHandlerConstruct) IteratorAgeGraphWidget() awscloudwatch.GraphWidget {
+	region := c.Handler.Stack().Region()
+
+	iteratorAgeMetric := c.Dashboard.CreateLambdaMetric(*region, "IteratorAge", c.Handler.FunctionName(), "Maximum")
+	metrics := []awscloudwatch.IMetric{iteratorAgeMetric}
+
+	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%s - Iterator Age", c.Builder.HandlerId), metrics)
+}
+
+func (c StreamHandlerConstruct) DLQMetricsGraphWidget() awscloudwatch.GraphWidget {
+	region := c.DLQ.Stack().Region()
+	queueName := c.DLQ.QueueName()
+
+	visibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesVisible", queueName, "Sum")
+	invisibleMetric := c.Dashboard.CreateQueueMetric(*region, "ApproximateNumberOfMessagesNotVisible", queueName, "Sum")
+	metrics := []awscloudwatch.IMetric{visibleMetric, invisibleMetric}
+
+	return c.Dashboard.CreateGraphWidget(*region, fmt.Sprintf("%sDLQ - Visible & Invisible", c.Builder.HandlerId), metrics)
+}
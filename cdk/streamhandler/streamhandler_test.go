@@ -0,0 +1,76 @@
+package streamhandler
+
+import (
+	"testing"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func newTestTemplate(builder StreamHandlerBuilder) assertions.Template {
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, aws.String("TestStack"), nil)
+
+	builder.SourceTable = awsdynamodb.NewTable(stack, aws.String("Messages"), &awsdynamodb.TableProps{
+		PartitionKey: &awsdynamodb.Attribute{Name: aws.String("PK"), Type: awsdynamodb.AttributeType_STRING},
+		Stream:       awsdynamodb.StreamViewType_NEW_AND_OLD_IMAGES,
+	})
+
+	builder.HandlerId = "MessagesHandler"
+	builder.Entry = "../queueconsumer/testdata/handler"
+
+	builder.Setup(stack, StreamCommonProps{})
+
+	return assertions.Template_FromStack(stack, nil)
+}
+
+func TestStreamHandler(t *testing.T) {
+	template := newTestTemplate(StreamHandlerBuilder{
+		StartingPosition:   awslambda.StartingPosition_TRIM_HORIZON,
+		BatchSize:          50,
+		BisectBatchOnError: true,
+		RetryAttempts:      3,
+		Filters:            []map[string]any{{"eventName": []any{"INSERT"}}},
+	})
+
+	template.HasResourceProperties(aws.String("AWS::Lambda::EventSourceMapping"), map[string]any{
+		"EventSourceArn":             map[string]any{"Fn::GetAtt": []any{assertions.Match_StringLikeRegexp(aws.String("^Messages")), "StreamArn"}},
+		"StartingPosition":           "TRIM_HORIZON",
+		"BatchSize":                  50,
+		"BisectBatchOnFunctionError": true,
+		"MaximumRetryAttempts":       3,
+		"FilterCriteria":             map[string]any{"Filters": []any{map[string]any{"Pattern": `{"eventName":["INSERT"]}`}}},
+		"DestinationConfig": map[string]any{"OnFailure": map[string]any{
+			"Destination": map[string]any{"Fn::GetAtt": []any{assertions.Match_StringLikeRegexp(aws.String("^MessagesHandlerDLQ")), "Arn"}},
+		}},
+	})
+
+	// the handler sends failed batches to its DLQ, and reads the table stream
+	template.HasResourceProperties(aws.String("AWS::IAM::Policy"), map[string]any{
+		"PolicyDocument": map[string]any{"Statement": assertions.Match_ArrayWith(&[]any{
+			assertions.Match_ObjectLike(&map[string]any{
+				"Action": assertions.Match_ArrayWith(&[]any{"sqs:SendMessage"}),
+			}),
+			assertions.Match_ObjectLike(&map[string]any{
+				"Action":   assertions.Match_ArrayWith(&[]any{"dynamodb:GetRecords", "dynamodb:GetShardIterator"}),
+				"Resource": map[string]any{"Fn::GetAtt": []any{assertions.Match_StringLikeRegexp(aws.String("^Messages")), "StreamArn"}},
+			}),
+		})},
+	})
+}
+
+func TestStreamHandlerDefaults(t *testing.T) {
+	template := newTestTemplate(StreamHandlerBuilder{})
+
+	// records are retried until they expire
+	template.HasResourceProperties(aws.String("AWS::Lambda::EventSourceMapping"), map[string]any{
+		"StartingPosition":           "LATEST",
+		"BatchSize":                  100,
+		"BisectBatchOnFunctionError": false,
+		"MaximumRetryAttempts":       assertions.Match_Absent(),
+		"FilterCriteria":             assertions.Match_Absent(),
+	})
+}
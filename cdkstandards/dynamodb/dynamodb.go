@@ -0,0 +1,172 @@
+// Package dynamodb provides a function that creates a DynamoDB table to our standards - KMS encryption,
+// point-in-time recovery, on-demand billing - with alarms on throttled requests and system errors.
+package dynamodb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/dbmanager"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatchactions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// DefaultRetainedEnvironments are the environments in which tables are retained when the stack is deleted.
+var DefaultRetainedEnvironments = []string{"prod", "production", "preprod"}
+
+// the operations covered by the alarms, as named by the Operation dimension - a math expression alarm takes at most
+// 10 metrics
+var alarmOperations = []string{
+	"GetItem",
+	"BatchGetItem",
+	"Scan",
+	"Query",
+	"GetRecords",
+	"PutItem",
+	"DeleteItem",
+	"UpdateItem",
+	"BatchWriteItem",
+	"TransactWriteItems",
+}
+
+// DynamoTableProps defines the configuration for the table and its alarms.
+type DynamoTableProps struct {
+	Stack     awscdk.Stack
+	TableName string
+	// If nil, the table is encrypted with the AWS managed key.
+	TableKey         awskms.IKey
+	Index            dbmanager.Index
+	SecondaryIndexes []dbmanager.Index
+	// If empty, items do not expire.
+	TimeToLiveAttribute string
+	// If empty, the table has no stream.
+	Stream awsdynamodb.StreamViewType
+	// Sets the removal policy, unless RemovalPolicy is given.
+	Environment string
+	// If empty, DefaultRetainedEnvironments.
+	RetainedEnvironments []string
+	RemovalPolicy        awscdk.RemovalPolicy
+	// Throttles and system errors share the alarm settings - periods in minutes, all default 1.
+	AlarmPeriod           int
+	AlarmThreshold        int
+	AlarmEvaluationPeriod int
+	AlarmTopics           []awssns.ITopic
+}
+
+// NewDynamoTable creates a new DynamoDB table with its secondary indexes, and alarms for throttled requests and
+// system errors.
+func NewDynamoTable(props DynamoTableProps) awsdynamodb.Table {
+	removalPolicy := props.RemovalPolicy
+	if removalPolicy == "" {
+		removalPolicy = RemovalPolicyForEnvironment(props.Environment, props.RetainedEnvironments)
+	}
+
+	alarmPeriod := awscdk.Duration_Minutes(aws.Float64(float64(max(props.AlarmPeriod, 1))))
+	alarmEvaluationPeriods := aws.Float64(float64(max(props.AlarmEvaluationPeriod, 1)))
+	alarmThreshold := aws.Float64(float64(max(props.AlarmThreshold, 1)))
+
+	tableProps := awsdynamodb.TableProps{
+		PartitionKey: props.Index.PartitionKey,
+		SortKey:      props.Index.SortKey,
+		BillingMode:  awsdynamodb.BillingMode_PAY_PER_REQUEST,
+		PointInTimeRecoverySpecification: &awsdynamodb.PointInTimeRecoverySpecification{
+			PointInTimeRecoveryEnabled: aws.Bool(true),
+		},
+		RemovalPolicy:      removalPolicy,
+		DeletionProtection: aws.Bool(removalPolicy == awscdk.RemovalPolicy_RETAIN),
+		Stream:             props.Stream,
+	}
+
+	if props.TableKey != nil {
+		tableProps.Encryption = awsdynamodb.TableEncryption_CUSTOMER_MANAGED
+		tableProps.EncryptionKey = props.TableKey
+	} else {
+		tableProps.Encryption = awsdynamodb.TableEncryption_AWS_MANAGED
+	}
+
+	if props.TimeToLiveAttribute != "" {
+		tableProps.TimeToLiveAttribute = aws.String(props.TimeToLiveAttribute)
+	}
+
+	table := awsdynamodb.NewTable(props.Stack, aws.String(props.TableName), &tableProps)
+
+	for _, index := range props.SecondaryIndexes {
+		index.AddTo(table)
+	}
+
+	// Throttle Alarm
+	throttleAlarm := awscloudwatch.NewAlarm(props.Stack, aws.String(props.TableName+"ThrottleAlarm"), &awscloudwatch.AlarmProps{
+		AlarmDescription:   aws.String("Alarm for " + props.TableName + " throttled requests"),
+		Metric:             operationsMetric(table, "ThrottledRequests", alarmPeriod),
+		Threshold:          alarmThreshold,
+		EvaluationPeriods:  alarmEvaluationPeriods,
+		ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+		TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+	})
+
+	// System Errors Alarm
+	errorsAlarm := awscloudwatch.NewAlarm(props.Stack, aws.String(props.TableName+"SystemErrorsAlarm"), &awscloudwatch.AlarmProps{
+		AlarmDescription:   aws.String("Alarm for " + props.TableName + " system errors"),
+		Metric:             operationsMetric(table, "SystemErrors", alarmPeriod),
+		Threshold:          alarmThreshold,
+		EvaluationPeriods:  alarmEvaluationPeriods,
+		ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+		TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+	})
+
+	for _, topic := range props.AlarmTopics {
+		throttleAlarm.AddAlarmAction(awscloudwatchactions.NewSnsAction(topic))
+		errorsAlarm.AddAlarmAction(awscloudwatchactions.NewSnsAction(topic))
+	}
+
+	return table
+}
+
+// operationsMetric sums the metric over alarmOperations. DynamoDB only publishes the metric for an operation in
+// periods when it was throttled or failed, so each term is filled with 0 - otherwise a sum with any missing term
+// would be missing.
+func operationsMetric(table awsdynamodb.Table, metricName string, period awscdk.Duration) awscloudwatch.MathExpression {
+	usingMetrics := map[string]awscloudwatch.IMetric{}
+	terms := make([]string, len(alarmOperations))
+
+	for i, operation := range alarmOperations {
+		id := strings.ToLower(operation)
+
+		usingMetrics[id] = table.Metric(aws.String(metricName), &awscloudwatch.MetricOptions{
+			DimensionsMap: &map[string]*string{"TableName": table.TableName(), "Operation": aws.String(operation)},
+			Period:        period,
+			Statistic:     aws.String("sum"),
+		})
+
+		terms[i] = fmt.Sprintf("FILL(%s,0)", id)
+	}
+
+	return awscloudwatch.NewMathExpression(&awscloudwatch.MathExpressionProps{
+		Expression:   aws.String(strings.Join(terms, " + ")),
+		UsingMetrics: &usingMetrics,
+		Label:        aws.String(metricName),
+		Period:       period,
+	})
+}
+
+// RemovalPolicyForEnvironment retains tables in the retained environments - DefaultRetainedEnvironments if empty - and
+// destroys them elsewhere.
+func RemovalPolicyForEnvironment(environment string, retainedEnvironments []string) awscdk.RemovalPolicy {
+	if len(retainedEnvironments) == 0 {
+		retainedEnvironments = DefaultRetainedEnvironments
+	}
+
+	for _, retained := range retainedEnvironments {
+		if strings.EqualFold(environment, retained) {
+			return awscdk.RemovalPolicy_RETAIN
+		}
+	}
+
+	return awscdk.RemovalPolicy_DESTROY
+}
@@ -0,0 +1,90 @@
+package dynamodb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/dbmanager"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTemplate(props DynamoTableProps) assertions.Template {
+	app := awscdk.NewApp(nil)
+	props.Stack = awscdk.NewStack(app, aws.String("TestStack"), nil)
+	props.TableName = "Messages"
+	props.Index = dbmanager.PrimaryIndex(dbmanager.StringAttribute("PK"), dbmanager.StringAttribute("SK"))
+
+	NewDynamoTable(props)
+
+	return assertions.Template_FromStack(props.Stack, nil)
+}
+
+func TestNewDynamoTable(t *testing.T) {
+	template := newTestTemplate(DynamoTableProps{
+		SecondaryIndexes:    []dbmanager.Index{dbmanager.GlobalIndex("OwnerIndex", dbmanager.StringAttribute("Owner"), nil)},
+		TimeToLiveAttribute: "Expires",
+	})
+
+	template.HasResourceProperties(aws.String("AWS::DynamoDB::Table"), map[string]any{
+		"BillingMode":                      "PAY_PER_REQUEST",
+		"PointInTimeRecoverySpecification": map[string]any{"PointInTimeRecoveryEnabled": true},
+		"SSESpecification":                 map[string]any{"SSEEnabled": true},
+		"TimeToLiveSpecification":          map[string]any{"AttributeName": "Expires", "Enabled": true},
+		"GlobalSecondaryIndexes":           assertions.Match_ArrayWith(&[]any{assertions.Match_ObjectLike(&map[string]any{"IndexName": "OwnerIndex"})}),
+	})
+
+	template.HasResource(aws.String("AWS::DynamoDB::Table"), map[string]any{"DeletionPolicy": "Delete"})
+}
+
+func TestNewDynamoTableAlarmDefaults(t *testing.T) {
+	template := newTestTemplate(DynamoTableProps{AlarmThreshold: 1})
+
+	template.ResourceCountIs(aws.String("AWS::CloudWatch::Alarm"), aws.Float64(2))
+	template.AllResourcesProperties(aws.String("AWS::CloudWatch::Alarm"), map[string]any{"EvaluationPeriods": 1})
+
+	alarms, err := json.Marshal(template.FindResources(aws.String("AWS::CloudWatch::Alarm"), nil))
+	assert.Nil(t, err)
+	assert.Contains(t, string(alarms), `"Period":60`)
+	assert.NotContains(t, string(alarms), `"Period":0`)
+}
+
+func TestNewDynamoTableAlarmExpression(t *testing.T) {
+	template := newTestTemplate(DynamoTableProps{})
+
+	// missing operation metrics count as 0, rather than making the sum missing
+	expression := "FILL(getitem,0) + FILL(batchgetitem,0) + FILL(scan,0) + FILL(query,0) + FILL(getrecords,0) + " +
+		"FILL(putitem,0) + FILL(deleteitem,0) + FILL(updateitem,0) + FILL(batchwriteitem,0) + FILL(transactwriteitems,0)"
+
+	for _, metricName := range []string{"ThrottledRequests", "SystemErrors"} {
+		template.HasResourceProperties(aws.String("AWS::CloudWatch::Alarm"), map[string]any{
+			"Threshold":        1,
+			"TreatMissingData": "notBreaching",
+			"Metrics": assertions.Match_ArrayWith(&[]any{
+				assertions.Match_ObjectLike(&map[string]any{"Expression": expression, "Label": metricName}),
+				assertions.Match_ObjectLike(&map[string]any{
+					"Id": "putitem",
+					"MetricStat": assertions.Match_ObjectLike(&map[string]any{
+						"Metric": assertions.Match_ObjectLike(&map[string]any{
+							"MetricName": metricName,
+							"Dimensions": assertions.Match_ArrayWith(&[]any{map[string]any{"Name": "Operation", "Value": "PutItem"}}),
+						}),
+					}),
+				}),
+			}),
+		})
+	}
+}
+
+func TestRemovalPolicyForEnvironment(t *testing.T) {
+	assert.Equal(t, awscdk.RemovalPolicy_RETAIN, RemovalPolicyForEnvironment("Prod", nil))
+	assert.Equal(t, awscdk.RemovalPolicy_DESTROY, RemovalPolicyForEnvironment("dev", nil))
+	assert.Equal(t, awscdk.RemovalPolicy_RETAIN, RemovalPolicyForEnvironment("uat", []string{"uat"}))
+	assert.Equal(t, awscdk.RemovalPolicy_DESTROY, RemovalPolicyForEnvironment("prod", []string{"uat"}))
+
+	template := newTestTemplate(DynamoTableProps{Environment: "uat", RetainedEnvironments: []string{"uat"}})
+	template.HasResource(aws.String("AWS::DynamoDB::Table"), map[string]any{"DeletionPolicy": "Retain"})
+}
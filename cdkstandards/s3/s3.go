@@ -0,0 +1,59 @@
+// Package s3 provides a function that creates an S3 bucket for claim-check payloads - private, encrypted, SSL-only,
+// with objects expiring once the messages that refer to them can no longer be received.
+package s3
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// ClaimCheckBucketProps defines the configuration for the bucket.
+type ClaimCheckBucketProps struct {
+	Stack      awscdk.Stack
+	BucketName string
+	// If nil, the bucket is encrypted with the S3 managed key.
+	BucketKey awskms.IKey
+	// Should be no less than the retention period of the queues and DLQs that receive the messages. Default: 14
+	ExpirationDays int
+	// If empty, the bucket and its objects are destroyed with the stack.
+	RemovalPolicy awscdk.RemovalPolicy
+}
+
+// NewClaimCheckBucket creates a new bucket for claim-check payloads.
+func NewClaimCheckBucket(props ClaimCheckBucketProps) awss3.Bucket {
+	expirationDays := props.ExpirationDays
+	if expirationDays == 0 {
+		expirationDays = 14
+	}
+
+	removalPolicy := props.RemovalPolicy
+	if removalPolicy == "" {
+		removalPolicy = awscdk.RemovalPolicy_DESTROY
+	}
+
+	bucketProps := awss3.BucketProps{
+		BlockPublicAccess: awss3.BlockPublicAccess_BLOCK_ALL(),
+		EnforceSSL:        aws.Bool(true),
+		LifecycleRules: &[]*awss3.LifecycleRule{
+			{
+				Id:                                  aws.String("ExpireClaimChecks"),
+				Expiration:                          awscdk.Duration_Days(aws.Float64(float64(expirationDays))),
+				AbortIncompleteMultipartUploadAfter: awscdk.Duration_Days(aws.Float64(1)),
+			},
+		},
+		RemovalPolicy:     removalPolicy,
+		AutoDeleteObjects: aws.Bool(removalPolicy == awscdk.RemovalPolicy_DESTROY),
+	}
+
+	if props.BucketKey != nil {
+		bucketProps.Encryption = awss3.BucketEncryption_KMS
+		bucketProps.EncryptionKey = props.BucketKey
+		bucketProps.BucketKeyEnabled = aws.Bool(true)
+	} else {
+		bucketProps.Encryption = awss3.BucketEncryption_S3_MANAGED
+	}
+
+	return awss3.NewBucket(props.Stack, aws.String(props.BucketName), &bucketProps)
+}
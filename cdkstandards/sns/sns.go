@@ -0,0 +1,46 @@
+// Package sns provides a function that creates an SNS topic to our standards - KMS encryption, SSL only, active
+// tracing - either standard, or FIFO for ordered fan-out to FIFO queues.
+package sns
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awskms"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TopicProps defines the configuration for the topic.
+type TopicProps struct {
+	Stack     awscdk.Stack
+	TopicName string
+	// If nil, the topic is encrypted with the AWS managed key - which services such as CloudWatch alarms and
+	// EventBridge cannot use to publish.
+	TopicKey awskms.IKey
+	Fifo     bool
+	// FIFO only - if false, publishers must give a MessageDeduplicationId.
+	ContentBasedDeduplication bool
+	// FIFO only - MESSAGE_GROUP allows higher throughput, with deduplication per message group. Default: TOPIC
+	FifoThroughputScope awssns.FifoThroughputScope
+}
+
+// NewTopic creates a new SNS topic - a FIFO topic's name is given the .fifo suffix.
+func NewTopic(props TopicProps) awssns.Topic {
+	topicKey := props.TopicKey
+	if topicKey == nil {
+		topicKey = awskms.Alias_FromAliasName(props.Stack, aws.String(props.TopicName+"Key"), aws.String("alias/aws/sns"))
+	}
+
+	topicProps := awssns.TopicProps{
+		MasterKey:     topicKey,
+		EnforceSSL:    aws.Bool(true),
+		TracingConfig: awssns.TracingConfig_ACTIVE,
+	}
+
+	if props.Fifo {
+		topicProps.Fifo = aws.Bool(true)
+		topicProps.ContentBasedDeduplication = aws.Bool(props.ContentBasedDeduplication)
+		topicProps.FifoThroughputScope = props.FifoThroughputScope
+	}
+
+	return awssns.NewTopic(props.Stack, aws.String(props.TopicName), &topicProps)
+}
@@ -0,0 +1,84 @@
+// Command dlqinspect peeks at the messages in a DLQ without deleting them, summarising them by error signature or
+// event type, and dumping them to JSONL for offline analysis.
+//
+//	dlqinspect -queue https://sqs.eu-west-2.amazonaws.com/673007244143/SQS1QueueDLQ -group-by error -dump dlq.jsonl
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/sqsmanager"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/joerdav/zapray"
+)
+
+func main() {
+	queue := flag.String("queue", "", "URL of the DLQ")
+	maxMessages := flag.Int("max", 0, "maximum number of messages to inspect (default: unlimited)")
+	visibilityTimeout := flag.Int("visibility-timeout", 0, "seconds the inspected messages are hidden, if the scan is interrupted - longer than the scan (default: 300, or longer for a large max)")
+	groupBy := flag.String("group-by", sqsmanager.GroupByEventType, "error or event-type")
+	dumpFile := flag.String("dump", "", "JSONL dump file (default: no dump)")
+	flag.Parse()
+
+	if *queue == "" || *groupBy != sqsmanager.GroupByError && *groupBy != sqsmanager.GroupByEventType {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(*queue, *maxMessages, int32(*visibilityTimeout), *groupBy, *dumpFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dlqinspect:", err)
+		os.Exit(1)
+	}
+}
+
+func run(queue string, maxMessages int, visibilityTimeout int32, groupBy string, dumpFile string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var dump io.Writer
+
+	if dumpFile != "" {
+		file, err := os.Create(dumpFile)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		dump = file
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger, err := zapray.NewProduction()
+	if err != nil {
+		return err
+	}
+
+	manager := sqsmanager.NewSQSManager(logger, cfg)
+
+	report, err := manager.Inspect(ctx, sqsmanager.InspectOptions{
+		QueueUrl:          queue,
+		MaxMessages:       maxMessages,
+		VisibilityTimeout: visibilityTimeout,
+		GroupBy:           groupBy,
+		Dump:              dump,
+	})
+
+	fmt.Printf("%d messages\n", report.Total)
+
+	for _, group := range report.Groups {
+		fmt.Printf("%6d  %s\n", group.Count, group.Key)
+	}
+
+	return err
+}
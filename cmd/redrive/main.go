@@ -0,0 +1,112 @@
+// Command redrive moves messages from a DLQ back to its source queue, or to any target queue.
+//
+//	redrive -source https://sqs.eu-west-2.amazonaws.com/673007244143/SQS1QueueDLQ -filter 'attr.EventType=TestMessage' \
+//		-max 100 -rate 10 -report redrive.jsonl -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/sqsmanager"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/joerdav/zapray"
+)
+
+// filterTerms is a repeatable flag.
+type filterTerms []string
+
+func (f *filterTerms) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *filterTerms) Set(term string) error {
+	*f = append(*f, term)
+	return nil
+}
+
+func main() {
+	var filter filterTerms
+
+	source := flag.String("source", "", "URL of the DLQ")
+	target := flag.String("target", "", "URL of the target queue (default: the DLQ's source queue)")
+	maxMessages := flag.Int("max", 0, "maximum number of messages to move (default: unlimited)")
+	rate := flag.Float64("rate", 0, "maximum messages per second (default: unlimited)")
+	dryRun := flag.Bool("dry-run", false, "report the messages that would be moved, without moving them")
+	preserveGroups := flag.Bool("preserve-groups", true, "send with the original message group IDs, for FIFO queues")
+	visibilityTimeout := flag.Int("visibility-timeout", 0, "seconds before messages that are not moved are visible again (default: the expected duration of the redrive)")
+	reportFile := flag.String("report", "", "JSONL report file (default: stdout)")
+	flag.Var(&filter, "filter", "body~REGEX, body=TEXT, attr.NAME~REGEX or attr.NAME=VALUE - may be repeated")
+	flag.Parse()
+
+	if *source == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	err := run(*source, *target, filter, *maxMessages, *rate, *dryRun, *preserveGroups, int32(*visibilityTimeout), *reportFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "redrive:", err)
+		os.Exit(1)
+	}
+}
+
+func run(source string, target string, filter filterTerms, maxMessages int, rate float64, dryRun bool, preserveGroups bool, visibilityTimeout int32, reportFile string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	messageFilter, err := sqsmanager.ParseFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	var report io.Writer = os.Stdout
+
+	if reportFile != "" {
+		file, err := os.Create(reportFile)
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		report = file
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	logger, err := zapray.NewProduction()
+	if err != nil {
+		return err
+	}
+
+	manager := sqsmanager.NewSQSManager(logger, cfg)
+
+	summary, err := manager.Redrive(ctx, sqsmanager.RedriveOptions{
+		SourceUrl:         source,
+		TargetUrl:         target,
+		Filter:            messageFilter,
+		MaxMessages:       maxMessages,
+		MessagesPerSecond: rate,
+		DryRun:            dryRun,
+		PreserveGroups:    preserveGroups,
+		VisibilityTimeout: visibilityTimeout,
+		Report:            report,
+	})
+
+	fmt.Fprintf(os.Stderr, "received: %d moved: %d skipped: %d failed: %d dry-run: %t\n", summary.Received, summary.Moved, summary.Skipped, summary.Failed, dryRun)
+
+	if summary.Truncated {
+		fmt.Fprintln(os.Stderr, "redrive: stopped when a message was received again - run again, with a longer -visibility-timeout")
+	}
+
+	return err
+}
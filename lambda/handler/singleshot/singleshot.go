@@ -3,6 +3,7 @@ package singleshot
 import (
 	"context"
 
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
 	"github.com/bruno-beloff-aviva/event-core/services"
 
 	"github.com/joerdav/zapray"
@@ -31,36 +32,38 @@ func NewSingleshotGateway[T any](logger *zapray.Logger, handler SingleshotHandle
 }
 
 func (g SingleshotGateway[T]) ProcessOnce(ctx context.Context, event T) (bool, error) {
-	g.logger.Debug("ProcessOnce: ", zap.Any("event", event))
+	logger := tracing.Logger(ctx, g.logger)
+
+	logger.Debug("ProcessOnce: ", zap.Any("event", event))
 
 	// Check...
 	policyOrQuoteID, eventID, err := g.handler.UniqueID(event)
 	if err != nil {
-		g.logger.Error("Error getting UniqueID", zap.Error(err))
+		logger.Error("Error getting UniqueID", zap.Error(err))
 		return false, err
 	}
 
 	eventHasBeenProcessed, err := g.eventHasBeenProcessed(ctx, policyOrQuoteID, eventID)
 	if err != nil {
-		g.logger.Error("Error checking if event has been processed", zap.Error(err))
+		logger.Error("Error checking if event has been processed", zap.Error(err))
 		return false, err
 	}
 
 	if eventHasBeenProcessed {
-		g.logger.Info("Event has already been processed")
+		logger.Info("Event has already been processed")
 		return false, nil
 	}
 
 	err = g.handler.Process(ctx, event)
 	if err != nil {
-		g.logger.Error("Process error", zap.Error(err))
+		logger.Error("Process error", zap.Error(err))
 		return true, err
 	}
 
 	// Mark as processed...
 	err = g.markEventAsProcessed(ctx, policyOrQuoteID, eventID)
 	if err != nil {
-		g.logger.Error("Error marking event as processed", zap.Error(err))
+		logger.Error("Error marking event as processed", zap.Error(err))
 		return true, nil
 	}
 
@@ -0,0 +1,124 @@
+package stream
+
+// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Streams.Lambda.html
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	Insert = "INSERT"
+	Modify = "MODIFY"
+	Remove = "REMOVE"
+)
+
+// Record is a stream record with its images decoded into the DynamoAble type T - NewImage is nil for REMOVE events,
+// OldImage is nil for INSERT events, or when the stream view type does not include it.
+type Record[T any] struct {
+	EventID   string
+	EventName string
+	NewImage  *T
+	OldImage  *T
+}
+
+func DecodeRecords[T any](event events.DynamoDBEvent) ([]Record[T], error) {
+	records := make([]Record[T], 0, len(event.Records))
+
+	for _, eventRecord := range event.Records {
+		record, err := DecodeRecord[T](eventRecord)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func DecodeRecord[T any](eventRecord events.DynamoDBEventRecord) (record Record[T], err error) {
+	record.EventID = eventRecord.EventID
+	record.EventName = eventRecord.EventName
+
+	record.NewImage, err = DecodeImage[T](eventRecord.Change.NewImage)
+	if err != nil {
+		return record, fmt.Errorf("NewImage of %s: %w", eventRecord.EventID, err)
+	}
+
+	record.OldImage, err = DecodeImage[T](eventRecord.Change.OldImage)
+	if err != nil {
+		return record, fmt.Errorf("OldImage of %s: %w", eventRecord.EventID, err)
+	}
+
+	return record, nil
+}
+
+// DecodeImage returns nil for an absent image.
+func DecodeImage[T any](image map[string]events.DynamoDBAttributeValue) (*T, error) {
+	if len(image) == 0 {
+		return nil, nil
+	}
+
+	var object T
+
+	err := attributevalue.UnmarshalMap(AttributeValueMap(image), &object)
+	if err != nil {
+		return nil, err
+	}
+
+	return &object, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// AttributeValueMap converts a Lambda event image to the SDK attribute values used by attributevalue.
+func AttributeValueMap(image map[string]events.DynamoDBAttributeValue) map[string]types.AttributeValue {
+	item := make(map[string]types.AttributeValue, len(image))
+
+	for name, value := range image {
+		item[name] = AttributeValue(value)
+	}
+
+	return item
+}
+
+func AttributeValue(value events.DynamoDBAttributeValue) types.AttributeValue {
+	switch value.DataType() {
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}
+
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}
+
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}
+
+	case events.DataTypeList:
+		list := make([]types.AttributeValue, len(value.List()))
+		for i, element := range value.List() {
+			list[i] = AttributeValue(element)
+		}
+		return &types.AttributeValueMemberL{Value: list}
+
+	case events.DataTypeMap:
+		return &types.AttributeValueMemberM{Value: AttributeValueMap(value.Map())}
+
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: value.Number()}
+
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}
+
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}
+
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}
+	}
+
+	return &types.AttributeValueMemberNULL{Value: true}
+}
@@ -0,0 +1,35 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/bruno-beloff-aviva/event-core/service/testreception"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeRecord(t *testing.T) {
+	event := `{"eventID":"1","eventName":"INSERT","dynamodb":{"NewImage":{"PK":{"S":"sent/sub1"},"Received":{"S":"received"},"Subscriber":{"S":"sub1"},"Path":{"S":"/test1/ok1"},"Client":{"S":"client"}}}}`
+
+	var eventRecord events.DynamoDBEventRecord
+
+	err := json.Unmarshal([]byte(event), &eventRecord)
+	if err != nil {
+		panic(err)
+	}
+
+	record, err := DecodeRecord[testreception.TestReception](eventRecord)
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(record.NewImage.String())
+
+	assert.Equal(t, Insert, record.EventName)
+	assert.Nil(t, record.OldImage)
+	assert.Equal(t, "sub1", record.NewImage.Subscriber)
+	assert.Equal(t, "/test1/ok1", record.NewImage.Path)
+}
@@ -0,0 +1,34 @@
+package batch
+
+// Limits shared by the SQS, SNS and EventBridge batch APIs.
+const (
+	MaxEntries = 10
+	MaxBytes   = 256 * 1024
+)
+
+// Chunk splits entries, in order, into groups of at most maxEntries whose total size is at most maxBytes. An entry
+// that is itself larger than maxBytes is given a group of its own, for the service to reject.
+func Chunk[T any](entries []T, size func(T) int, maxEntries int, maxBytes int) [][]T {
+	var chunks [][]T
+	var chunk []T
+	chunkBytes := 0
+
+	for _, entry := range entries {
+		entryBytes := size(entry)
+
+		if len(chunk) > 0 && (len(chunk) == maxEntries || chunkBytes+entryBytes > maxBytes) {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			chunkBytes = 0
+		}
+
+		chunk = append(chunk, entry)
+		chunkBytes += entryBytes
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
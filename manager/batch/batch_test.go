@@ -0,0 +1,21 @@
+package batch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunkByCount(t *testing.T) {
+	entries := make([]int, 23)
+	chunks := Chunk(entries, func(int) int { return 1 }, MaxEntries, MaxBytes)
+
+	assert.Equal(t, []int{10, 10, 3}, []int{len(chunks[0]), len(chunks[1]), len(chunks[2])})
+}
+
+func TestChunkBySize(t *testing.T) {
+	entries := []int{100, 100, 60, 300, 10}
+	chunks := Chunk(entries, func(size int) int { return size }, MaxEntries, 256)
+
+	assert.Equal(t, [][]int{{100, 100}, {60}, {300}, {10}}, chunks)
+}
@@ -0,0 +1,177 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+)
+
+const RetryAttempts = 5
+
+// the backoff before the first retry, doubled for each retry after it
+var retryBackoff = 100 * time.Millisecond
+
+var (
+	ErrNotSent     = errors.New("entry was not sent")
+	ErrGroupFailed = errors.New("an earlier entry in the message group failed")
+)
+
+// EntryError is a failure reported by the service for a single entry of a batch.
+type EntryError struct {
+	Code      string
+	Message   string
+	Retryable bool
+}
+
+func (e EntryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// SendChunk sends the entries with the given indexes in a single request, returning the errors of those that failed -
+// a whole-request error is returned for every entry.
+type SendChunk func(ctx context.Context, chunk []int) map[int]error
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Retry sends the entries in chunks of at most MaxEntries and MaxBytes, retrying the failures that may succeed later,
+// with backoff. It returns the error of each entry, which is nil if the entry was sent, and ctx.Err() if cancelled.
+//
+// Entries with the same non-empty group - the MessageGroupId of a FIFO queue or topic - are sent in order: after a
+// failure, the rest of the group waits for the retry, and if the failure is final, or a later entry of the group has
+// already been sent, the rest of the group fails with ErrGroupFailed. Entries in the same request as the failure have
+// already been sent.
+func Retry[T any](ctx context.Context, entries []T, size func(T) int, group func(T) string, send SendChunk) ([]error, error) {
+	errs := make([]error, len(entries))
+	pending := make([]int, len(entries))
+
+	for i := range entries {
+		errs[i] = ErrNotSent
+		pending[i] = i
+	}
+
+	failedGroups := map[string]bool{}
+	backoff := retryBackoff
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		var retries []int
+
+		// groups with an entry awaiting retry
+		heldGroups := map[string]bool{}
+
+		for _, chunk := range Chunk(pending, func(i int) int { return size(entries[i]) }, MaxEntries, MaxBytes) {
+			var sendable []int
+
+			for _, i := range chunk {
+				switch name := group(entries[i]); {
+				case failedGroups[name]:
+					errs[i] = fmt.Errorf("%w: %s", ErrGroupFailed, name)
+				case heldGroups[name]:
+					retries = append(retries, i)
+				default:
+					sendable = append(sendable, i)
+				}
+			}
+
+			if len(sendable) == 0 {
+				continue
+			}
+
+			failures := send(ctx, sendable)
+
+			for _, i := range sendable {
+				name := group(entries[i])
+				err, failed := failures[i]
+
+				switch {
+				case !failed:
+					errs[i] = nil
+
+					// an earlier entry of the group can no longer be retried in order
+					if heldGroups[name] {
+						failedGroups[name] = true
+					}
+
+				case attempt < RetryAttempts && IsRetryable(err):
+					errs[i] = err
+					retries = append(retries, i)
+
+					if name != "" {
+						heldGroups[name] = true
+					}
+
+				default:
+					errs[i] = err
+
+					if name != "" {
+						failedGroups[name] = true
+					}
+				}
+			}
+		}
+
+		pending = nil
+
+		for _, i := range retries {
+			if name := group(entries[i]); failedGroups[name] {
+				if errs[i] == ErrNotSent {
+					errs[i] = fmt.Errorf("%w: %s", ErrGroupFailed, name)
+				}
+				continue
+			}
+
+			pending = append(pending, i)
+		}
+
+		if len(pending) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return errs, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+
+	return errs, nil
+}
+
+// IsRetryable reports whether the error may not recur - an EntryError that the service marks as retryable, or a
+// request that was throttled, failed on the server, or was not completed.
+func IsRetryable(err error) bool {
+	var entryError EntryError
+	if errors.As(err, &entryError) {
+		return entryError.Retryable
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiError smithy.APIError
+	if errors.As(err, &apiError) && apiError.ErrorFault() == smithy.FaultServer {
+		return true
+	}
+
+	return retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary
+}
+
+// Failed counts the errors that are not nil.
+func Failed(errs []error) int {
+	failed := 0
+
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+
+	return failed
+}
@@ -0,0 +1,168 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntry struct {
+	ID    int
+	Group string
+}
+
+func testSize(testEntry) int {
+	return 1
+}
+
+func testGroup(entry testEntry) string {
+	return entry.Group
+}
+
+// testSender fails each entry with the errors queued for its ID, and records the order in which entries are sent.
+type testSender struct {
+	failures map[int][]error
+	sent     []int
+	requests int
+}
+
+func (s *testSender) send(ctx context.Context, chunk []int) map[int]error {
+	s.requests++
+	errs := map[int]error{}
+
+	for _, i := range chunk {
+		if queued := s.failures[i]; len(queued) > 0 {
+			errs[i] = queued[0]
+			s.failures[i] = queued[1:]
+			continue
+		}
+
+		s.sent = append(s.sent, i)
+	}
+
+	return errs
+}
+
+func newTestEntries(groups ...string) []testEntry {
+	entries := make([]testEntry, len(groups))
+
+	for i, group := range groups {
+		entries[i] = testEntry{ID: i, Group: group}
+	}
+
+	return entries
+}
+
+func init() {
+	retryBackoff = time.Millisecond
+}
+
+var (
+	throttled = EntryError{Code: "ThrottlingException", Message: "slow down", Retryable: true}
+	rejected  = EntryError{Code: "InvalidParameterValue", Message: "no", Retryable: false}
+)
+
+func TestRetryEntryErrors(t *testing.T) {
+	sender := testSender{failures: map[int][]error{1: {throttled, throttled}, 2: {rejected}}}
+
+	errs, err := Retry(context.Background(), newTestEntries("", "", ""), testSize, testGroup, sender.send)
+	fmt.Println(errs)
+
+	assert.Nil(t, err)
+	assert.Nil(t, errs[0])
+	assert.Nil(t, errs[1])
+	assert.Equal(t, rejected, errs[2])
+	assert.Equal(t, 1, Failed(errs))
+	assert.Equal(t, 3, sender.requests)
+}
+
+func TestRetryRequestErrors(t *testing.T) {
+	accessDenied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "no", Fault: smithy.FaultClient}
+	sender := testSender{failures: map[int][]error{0: {accessDenied}}}
+
+	errs, err := Retry(context.Background(), newTestEntries(""), testSize, testGroup, sender.send)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, errs[0], accessDenied)
+	assert.Equal(t, 1, sender.requests)
+
+	throttling := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "slow down", Fault: smithy.FaultClient}
+	internal := &smithy.GenericAPIError{Code: "InternalError", Message: "oops", Fault: smithy.FaultServer}
+	sender = testSender{failures: map[int][]error{0: {throttling, internal}}}
+
+	errs, err = Retry(context.Background(), newTestEntries(""), testSize, testGroup, sender.send)
+	assert.Nil(t, err)
+	assert.Nil(t, errs[0])
+	assert.Equal(t, 3, sender.requests)
+}
+
+func TestRetryAttempts(t *testing.T) {
+	sender := testSender{failures: map[int][]error{0: {throttled, throttled, throttled, throttled, throttled, throttled}}}
+
+	errs, err := Retry(context.Background(), newTestEntries(""), testSize, testGroup, sender.send)
+	assert.Nil(t, err)
+	assert.Equal(t, throttled, errs[0])
+	assert.Equal(t, RetryAttempts, sender.requests)
+}
+
+func TestRetryGroupHeld(t *testing.T) {
+	// entries 0 and 1 fail in the first chunk, so entry 10 of the same group waits for their retry
+	groups := []string{"a", "a", "b", "b", "b", "b", "b", "b", "b", "b", "a"}
+	sender := testSender{failures: map[int][]error{0: {throttled}, 1: {throttled}}}
+
+	errs, err := Retry(context.Background(), newTestEntries(groups...), testSize, testGroup, sender.send)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, Failed(errs))
+
+	var groupA []int
+	for _, i := range sender.sent {
+		if groups[i] == "a" {
+			groupA = append(groupA, i)
+		}
+	}
+
+	assert.Equal(t, []int{0, 1, 10}, groupA)
+}
+
+func TestRetryGroupFailed(t *testing.T) {
+	// entry 0 fails after entry 1 of its group was sent, so it cannot be retried in order
+	sender := testSender{failures: map[int][]error{0: {throttled}, 2: {rejected}}}
+	groups := []string{"a", "a", "b", "", "", "", "", "", "", "", "b", "a", ""}
+
+	errs, err := Retry(context.Background(), newTestEntries(groups...), testSize, testGroup, sender.send)
+	fmt.Println(errs)
+
+	assert.Nil(t, err)
+	assert.Equal(t, throttled, errs[0])
+	assert.Nil(t, errs[1])
+	assert.ErrorIs(t, errs[11], ErrGroupFailed)
+
+	// entry 2 fails finally, so the rest of its group is not sent
+	assert.Equal(t, rejected, errs[2])
+	assert.ErrorIs(t, errs[10], ErrGroupFailed)
+	assert.NotContains(t, sender.sent, 10)
+	assert.NotContains(t, sender.sent, 11)
+	assert.Contains(t, sender.sent, 12)
+	assert.Equal(t, 4, Failed(errs))
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sender := testSender{failures: map[int][]error{0: {throttled}}}
+
+	send := func(ctx context.Context, chunk []int) map[int]error {
+		cancel()
+		return sender.send(ctx, chunk)
+	}
+
+	errs, err := Retry(ctx, newTestEntries("", ""), testSize, testGroup, send)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, throttled, errs[0])
+	assert.Nil(t, errs[1])
+	assert.False(t, IsRetryable(context.Canceled))
+	assert.False(t, IsRetryable(errors.New("unknown")))
+}
@@ -0,0 +1,181 @@
+package dbmanager
+
+// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Expressions.ConditionExpressions.html
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Condition is a condition on the attributes of an existing item. It is rendered as a DynamoDB condition expression
+// by DynamoManager, and evaluated directly by MemoryDynamoManager.
+type Condition struct {
+	operator string
+	name     string
+	value    any
+	operands []Condition
+}
+
+func AttributeExists(name string) Condition {
+	return Condition{operator: "attribute_exists", name: name}
+}
+
+func AttributeNotExists(name string) Condition {
+	return Condition{operator: "attribute_not_exists", name: name}
+}
+
+func Equal(name string, value any) Condition {
+	return Condition{operator: "=", name: name, value: value}
+}
+
+func NotEqual(name string, value any) Condition {
+	return Condition{operator: "<>", name: name, value: value}
+}
+
+func LessThan(name string, value any) Condition {
+	return Condition{operator: "<", name: name, value: value}
+}
+
+func LessThanOrEqual(name string, value any) Condition {
+	return Condition{operator: "<=", name: name, value: value}
+}
+
+func GreaterThan(name string, value any) Condition {
+	return Condition{operator: ">", name: name, value: value}
+}
+
+func GreaterThanOrEqual(name string, value any) Condition {
+	return Condition{operator: ">=", name: name, value: value}
+}
+
+func And(operands ...Condition) Condition {
+	return Condition{operator: "AND", operands: operands}
+}
+
+func Or(operands ...Condition) Condition {
+	return Condition{operator: "OR", operands: operands}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Expression renders the condition, adding its placeholders to names and values.
+func (c Condition) Expression(names map[string]string, values map[string]types.AttributeValue) string {
+	switch c.operator {
+	case "AND", "OR":
+		terms := make([]string, len(c.operands))
+		for i, operand := range c.operands {
+			terms[i] = "(" + operand.Expression(names, values) + ")"
+		}
+		return strings.Join(terms, " "+c.operator+" ")
+
+	case "attribute_exists", "attribute_not_exists":
+		return fmt.Sprintf("%s(%s)", c.operator, namePlaceholder(names, c.name))
+	}
+
+	return fmt.Sprintf("%s %s %s", namePlaceholder(names, c.name), c.operator, valuePlaceholder(values, c.value))
+}
+
+// Evaluate reports whether the condition holds for item, which is nil if the item does not exist.
+func (c Condition) Evaluate(item map[string]types.AttributeValue) bool {
+	switch c.operator {
+	case "AND":
+		for _, operand := range c.operands {
+			if !operand.Evaluate(item) {
+				return false
+			}
+		}
+		return true
+
+	case "OR":
+		for _, operand := range c.operands {
+			if operand.Evaluate(item) {
+				return true
+			}
+		}
+		return false
+	}
+
+	attribute, exists := item[c.name]
+
+	switch c.operator {
+	case "attribute_exists":
+		return exists
+
+	case "attribute_not_exists":
+		return !exists
+	}
+
+	if !exists {
+		return false
+	}
+
+	comparison, comparable := compareAttributeValues(attribute, dBKeyMarshal(c.value))
+
+	switch c.operator {
+	case "=":
+		return comparable && comparison == 0
+	case "<>":
+		return !comparable || comparison != 0
+	case "<":
+		return comparable && comparison < 0
+	case "<=":
+		return comparable && comparison <= 0
+	case ">":
+		return comparable && comparison > 0
+	case ">=":
+		return comparable && comparison >= 0
+	}
+
+	return false
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func namePlaceholder(names map[string]string, name string) string {
+	placeholder := fmt.Sprintf("#c%d", len(names))
+	names[placeholder] = name
+
+	return placeholder
+}
+
+func valuePlaceholder(values map[string]types.AttributeValue, value any) string {
+	placeholder := fmt.Sprintf(":c%d", len(values))
+	values[placeholder] = dBKeyMarshal(value)
+
+	return placeholder
+}
+
+// compareAttributeValues orders scalar values of the same type - numbers numerically, strings and binaries bytewise.
+func compareAttributeValues(a types.AttributeValue, b types.AttributeValue) (comparison int, comparable bool) {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberS:
+		if b, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(a.Value, b.Value), true
+		}
+
+	case *types.AttributeValueMemberN:
+		if b, ok := b.(*types.AttributeValueMemberN); ok {
+			aNumber, aOk := new(big.Float).SetString(a.Value)
+			bNumber, bOk := new(big.Float).SetString(b.Value)
+			if aOk && bOk {
+				return aNumber.Cmp(bNumber), true
+			}
+		}
+
+	case *types.AttributeValueMemberB:
+		if b, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(a.Value, b.Value), true
+		}
+
+	case *types.AttributeValueMemberBOOL:
+		if b, ok := b.(*types.AttributeValueMemberBOOL); ok && a.Value == b.Value {
+			return 0, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,163 @@
+package dbmanager
+
+// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/S3DataExport.Output.html
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MarshalDynamoJSON encodes an item in DynamoDB JSON, where each value is tagged with its type, e.g. {"S": "abc"}.
+func MarshalDynamoJSON(item map[string]types.AttributeValue) ([]byte, error) {
+	return json.Marshal(dynamoJSONMap(item))
+}
+
+func UnmarshalDynamoJSON(data []byte) (map[string]types.AttributeValue, error) {
+	var jsonItem map[string]map[string]json.RawMessage
+
+	err := json.Unmarshal(data, &jsonItem)
+	if err != nil {
+		return nil, err
+	}
+
+	return attributeValueMap(jsonItem)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func dynamoJSONMap(item map[string]types.AttributeValue) map[string]any {
+	jsonItem := make(map[string]any, len(item))
+
+	for name, value := range item {
+		jsonItem[name] = dynamoJSONValue(value)
+	}
+
+	return jsonItem
+}
+
+// []byte values are base64 encoded by encoding/json
+func dynamoJSONValue(value types.AttributeValue) map[string]any {
+	switch value := value.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": value.Value}
+
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": value.Value}
+
+	case *types.AttributeValueMemberB:
+		return map[string]any{"B": value.Value}
+
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": value.Value}
+
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": value.Value}
+
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": value.Value}
+
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": value.Value}
+
+	case *types.AttributeValueMemberBS:
+		return map[string]any{"BS": value.Value}
+
+	case *types.AttributeValueMemberM:
+		return map[string]any{"M": dynamoJSONMap(value.Value)}
+
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(value.Value))
+		for i, element := range value.Value {
+			list[i] = dynamoJSONValue(element)
+		}
+		return map[string]any{"L": list}
+	}
+
+	return map[string]any{"NULL": true}
+}
+
+func attributeValueMap(jsonItem map[string]map[string]json.RawMessage) (map[string]types.AttributeValue, error) {
+	item := make(map[string]types.AttributeValue, len(jsonItem))
+
+	for name, jsonValue := range jsonItem {
+		value, err := attributeValue(jsonValue)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		item[name] = value
+	}
+
+	return item, nil
+}
+
+func attributeValue(jsonValue map[string]json.RawMessage) (types.AttributeValue, error) {
+	if len(jsonValue) != 1 {
+		return nil, fmt.Errorf("a DynamoDB JSON value must have exactly one type")
+	}
+
+	for dataType, raw := range jsonValue {
+		switch dataType {
+		case "S":
+			value := types.AttributeValueMemberS{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "N":
+			value := types.AttributeValueMemberN{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "B":
+			value := types.AttributeValueMemberB{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "BOOL":
+			value := types.AttributeValueMemberBOOL{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "NULL":
+			value := types.AttributeValueMemberNULL{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "SS":
+			value := types.AttributeValueMemberSS{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "NS":
+			value := types.AttributeValueMemberNS{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "BS":
+			value := types.AttributeValueMemberBS{}
+			return &value, json.Unmarshal(raw, &value.Value)
+
+		case "M":
+			var jsonItem map[string]map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &jsonItem); err != nil {
+				return nil, err
+			}
+			item, err := attributeValueMap(jsonItem)
+			return &types.AttributeValueMemberM{Value: item}, err
+
+		case "L":
+			var jsonList []map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &jsonList); err != nil {
+				return nil, err
+			}
+			list := make([]types.AttributeValue, len(jsonList))
+			for i, jsonElement := range jsonList {
+				element, err := attributeValue(jsonElement)
+				if err != nil {
+					return nil, err
+				}
+				list[i] = element
+			}
+			return &types.AttributeValueMemberL{Value: list}, nil
+		}
+
+		return nil, fmt.Errorf("unknown DynamoDB JSON type: %s", dataType)
+	}
+
+	return nil, nil
+}
@@ -7,6 +7,10 @@ package dbmanager
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
@@ -23,10 +27,34 @@ type DynamoAble interface {
 	PartitionKey() map[string]any
 }
 
+// DBManager is implemented by DynamoManager, and by MemoryDynamoManager for unit tests.
+type DBManager interface {
+	TableIsAvailable(ctx context.Context) bool
+	Get(ctx context.Context, object DynamoAble) error
+	Put(ctx context.Context, object DynamoAble) error
+	PutIf(ctx context.Context, object DynamoAble, condition Condition) error
+	Increment(ctx context.Context, object DynamoAble, field string) error
+	Add(ctx context.Context, object DynamoAble, field string, delta int64) error
+	Query(ctx context.Context, index Index, condition KeyCondition, items any) error
+}
+
+var ErrConditionFailed = errors.New("condition failed")
+
+// KeyCondition selects items by partition key value, optionally narrowed by a condition on the sort key.
+// SortOperator is one of =, <, <=, >, >=, BETWEEN or begins_with - BETWEEN takes two SortValues.
+type KeyCondition struct {
+	PartitionValue any
+	SortOperator   string
+	SortValues     []any
+	Descending     bool
+	Limit          int32
+}
+
 type DynamoManager struct {
 	logger    *zapray.Logger
 	dBClient  *dynamodb.Client
 	tableName string
+	encryptor *attributeEncryptor
 }
 
 func StringAttribute(keyName string) *awsdynamodb.Attribute {
@@ -39,6 +67,14 @@ func NewDynamoManager(logger *zapray.Logger, cfg aws.Config, tableName string) D
 	return DynamoManager{logger: logger, dBClient: dBClient, tableName: tableName}
 }
 
+// WithEncryption returns a manager that encrypts the fields tagged `encrypted:"true"` under data keys from keyring.
+// The table's index and secondary indexes give the key attributes, which cannot be encrypted.
+func (m DynamoManager) WithEncryption(keyring Keyring, index Index, secondaryIndexes ...Index) DynamoManager {
+	m.encryptor = newAttributeEncryptor(keyring, index, secondaryIndexes)
+
+	return m
+}
+
 // ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 func (m DynamoManager) TableIsAvailable(ctx context.Context) bool {
@@ -64,6 +100,12 @@ func (m DynamoManager) Get(ctx context.Context, object DynamoAble) error {
 	if err != nil {
 		m.logger.Error("GetItem: ", zap.Any("key", object.PartitionKey()), zap.Error(err))
 	} else {
+		err = m.decrypt(ctx, reflect.TypeOf(object), response.Item)
+		if err != nil {
+			m.logger.Error("GetItem: ", zap.Any("key", object.PartitionKey()), zap.Error(err))
+			return err
+		}
+
 		err = attributevalue.UnmarshalMap(response.Item, &object)
 		if err != nil {
 			panic(err)
@@ -76,9 +118,10 @@ func (m DynamoManager) Get(ctx context.Context, object DynamoAble) error {
 func (m DynamoManager) Put(ctx context.Context, object DynamoAble) error {
 	m.logger.Debug("Put: ", zap.Any("object", object))
 
-	item, err := attributevalue.MarshalMap(object)
+	item, err := m.marshal(ctx, object)
 	if err != nil {
-		panic(err)
+		m.logger.Error("PutItem: ", zap.Error(err))
+		return err
 	}
 
 	params := dynamodb.PutItemInput{
@@ -94,6 +137,19 @@ func (m DynamoManager) Put(ctx context.Context, object DynamoAble) error {
 	return err
 }
 
+// PutIf puts the object only if condition holds for the existing item, otherwise it returns ErrConditionFailed.
+func (m DynamoManager) PutIf(ctx context.Context, object DynamoAble, condition Condition) error {
+	m.logger.Debug("PutIf: ", zap.Any("object", object))
+
+	item, err := m.marshal(ctx, object)
+	if err != nil {
+		m.logger.Error("PutItem: ", zap.Error(err))
+		return err
+	}
+
+	return m.putItemIf(ctx, item, condition)
+}
+
 func (m DynamoManager) Increment(ctx context.Context, object DynamoAble, field string) (err error) {
 	m.logger.Debug("Increment: ", zap.Any("object", object), zap.String("field", field))
 
@@ -118,8 +174,210 @@ func (m DynamoManager) Increment(ctx context.Context, object DynamoAble, field s
 	return err
 }
 
+// Add adds delta to the numeric field in a single atomic update, creating the item - with only its key attributes
+// and the field - if it does not exist. Unlike Increment, concurrent calls on a new item cannot lose updates.
+func (m DynamoManager) Add(ctx context.Context, object DynamoAble, field string, delta int64) error {
+	m.logger.Debug("Add: ", zap.Any("object", object), zap.String("field", field), zap.Int64("delta", delta))
+
+	_, err := m.dBClient.UpdateItem(ctx, addInput(m.tableName, object, field, delta))
+
+	return err
+}
+
+// Query finds the items matching condition on the table or secondary index, and unmarshals them into items, which
+// must be a pointer to a slice.
+func (m DynamoManager) Query(ctx context.Context, index Index, condition KeyCondition, items any) error {
+	m.logger.Debug("Query: ", zap.String("index", index.IndexName), zap.Any("condition", condition))
+
+	params, err := queryInput(m.tableName, index, condition)
+	if err != nil {
+		return err
+	}
+
+	var found []map[string]types.AttributeValue
+
+	for {
+		response, err := m.dBClient.Query(ctx, params)
+		if err != nil {
+			m.logger.Error("Query: ", zap.String("index", index.IndexName), zap.Error(err))
+			return err
+		}
+
+		found = append(found, response.Items...)
+
+		if response.LastEvaluatedKey == nil || (condition.Limit > 0 && int32(len(found)) >= condition.Limit) {
+			break
+		}
+
+		params.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+
+	if condition.Limit > 0 && int32(len(found)) > condition.Limit {
+		found = found[:condition.Limit]
+	}
+
+	for _, item := range found {
+		err = m.decrypt(ctx, reflect.TypeOf(items), item)
+		if err != nil {
+			m.logger.Error("Query: ", zap.String("index", index.IndexName), zap.Error(err))
+			return err
+		}
+	}
+
+	return attributevalue.UnmarshalListOfMaps(found, items)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (m DynamoManager) putItemIf(ctx context.Context, item map[string]types.AttributeValue, condition Condition) error {
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+
+	params := dynamodb.PutItemInput{
+		TableName:                 jsii.String(m.tableName),
+		Item:                      item,
+		ConditionExpression:       jsii.String(condition.Expression(names, values)),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	}
+
+	if len(values) == 0 {
+		params.ExpressionAttributeValues = nil
+	}
+
+	_, err := m.dBClient.PutItem(ctx, &params)
+
+	var conditionalCheckFailed *types.ConditionalCheckFailedException
+	if errors.As(err, &conditionalCheckFailed) {
+		return ErrConditionFailed
+	}
+
+	if err != nil {
+		m.logger.Error("PutItem: ", zap.Error(err))
+	}
+
+	return err
+}
+
+func (m DynamoManager) scanSegment(ctx context.Context, segment int, totalSegments int, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	params := dynamodb.ScanInput{
+		TableName:         jsii.String(m.tableName),
+		Segment:           aws.Int32(int32(segment)),
+		TotalSegments:     aws.Int32(int32(totalSegments)),
+		ExclusiveStartKey: startKey,
+	}
+
+	response, err := m.dBClient.Scan(ctx, &params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response.Items, response.LastEvaluatedKey, nil
+}
+
+func (m DynamoManager) marshal(ctx context.Context, object DynamoAble) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(object)
+	if err != nil {
+		panic(err)
+	}
+
+	if m.encryptor == nil {
+		return item, nil
+	}
+
+	return item, m.encryptor.encrypt(ctx, object, item)
+}
+
+func (m DynamoManager) decrypt(ctx context.Context, objectType reflect.Type, item map[string]types.AttributeValue) error {
+	if m.encryptor == nil || item == nil {
+		return nil
+	}
+
+	return m.encryptor.decrypt(ctx, objectType, item)
+}
+
 // ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
+func addInput(tableName string, object DynamoAble, field string, delta int64) *dynamodb.UpdateItemInput {
+	return &dynamodb.UpdateItemInput{
+		Key:                       getDBKey(object),
+		TableName:                 aws.String(tableName),
+		ExpressionAttributeNames:  map[string]string{"#field": field},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":delta": &types.AttributeValueMemberN{Value: strconv.FormatInt(delta, 10)}},
+		UpdateExpression:          aws.String("ADD #field :delta"),
+	}
+}
+
+func queryInput(tableName string, index Index, condition KeyCondition) (*dynamodb.QueryInput, error) {
+	names := map[string]string{"#pk": index.PartitionKeyName()}
+	values := map[string]types.AttributeValue{":pk": dBKeyMarshal(condition.PartitionValue)}
+	expression := "#pk = :pk"
+
+	if condition.SortOperator != "" {
+		if index.SortKey == nil {
+			return nil, fmt.Errorf("index %q has no sort key", index.IndexName)
+		}
+
+		names["#sk"] = index.SortKeyName()
+
+		sortExpression, err := sortKeyExpression(condition, values)
+		if err != nil {
+			return nil, err
+		}
+
+		expression += " AND " + sortExpression
+	}
+
+	params := dynamodb.QueryInput{
+		TableName:                 jsii.String(tableName),
+		KeyConditionExpression:    jsii.String(expression),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ScanIndexForward:          aws.Bool(!condition.Descending),
+	}
+
+	if index.Kind != PrimaryIndexKind {
+		params.IndexName = jsii.String(index.IndexName)
+	}
+
+	if condition.Limit > 0 {
+		params.Limit = aws.Int32(condition.Limit)
+	}
+
+	return &params, nil
+}
+
+func sortKeyExpression(condition KeyCondition, values map[string]types.AttributeValue) (string, error) {
+	switch condition.SortOperator {
+	case "=", "<", "<=", ">", ">=":
+		if len(condition.SortValues) != 1 {
+			return "", fmt.Errorf("sort operator %s requires one value", condition.SortOperator)
+		}
+
+		values[":sk"] = dBKeyMarshal(condition.SortValues[0])
+		return "#sk " + condition.SortOperator + " :sk", nil
+
+	case "BETWEEN":
+		if len(condition.SortValues) != 2 {
+			return "", fmt.Errorf("sort operator BETWEEN requires two values")
+		}
+
+		values[":sk0"] = dBKeyMarshal(condition.SortValues[0])
+		values[":sk1"] = dBKeyMarshal(condition.SortValues[1])
+		return "#sk BETWEEN :sk0 AND :sk1", nil
+
+	case "begins_with":
+		if len(condition.SortValues) != 1 {
+			return "", fmt.Errorf("sort operator begins_with requires one value")
+		}
+
+		values[":sk"] = dBKeyMarshal(condition.SortValues[0])
+		return "begins_with(#sk, :sk)", nil
+	}
+
+	return "", fmt.Errorf("unknown sort operator: %s", condition.SortOperator)
+}
+
 func dBKeyMap(objectKey map[string]any, marshal func(any) types.AttributeValue) map[string]types.AttributeValue {
 	dBKey := make(map[string]types.AttributeValue, len(objectKey))
 
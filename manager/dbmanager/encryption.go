@@ -0,0 +1,207 @@
+package dbmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Fields tagged `encrypted:"true"` are encrypted by DynamoManager.Put, under a data key that is stored, encrypted by
+// the keyring, in the item's EncryptedDataKey attribute. Each ciphertext is bound to its attribute name and the item's
+// primary key, so it cannot be copied to another attribute or item. Encrypted attributes cannot be used in keys -
+// of the table or its secondary indexes - or in conditions.
+const (
+	encryptedTag              = "encrypted"
+	encryptedDataKeyAttribute = "EncryptedDataKey"
+)
+
+var ErrEncryptedKeyAttribute = errors.New("an encrypted attribute cannot be a key attribute")
+
+type attributeEncryptor struct {
+	keyring       Keyring
+	index         Index
+	keyAttributes map[string]bool
+}
+
+func newAttributeEncryptor(keyring Keyring, index Index, secondaryIndexes []Index) *attributeEncryptor {
+	keyAttributes := map[string]bool{}
+
+	for _, keyIndex := range append([]Index{index}, secondaryIndexes...) {
+		keyAttributes[keyIndex.PartitionKeyName()] = true
+
+		if keyIndex.SortKey != nil {
+			keyAttributes[keyIndex.SortKeyName()] = true
+		}
+	}
+
+	return &attributeEncryptor{keyring: keyring, index: index, keyAttributes: keyAttributes}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (e attributeEncryptor) encrypt(ctx context.Context, object DynamoAble, item map[string]types.AttributeValue) error {
+	names := EncryptedAttributes(reflect.TypeOf(object))
+	if len(names) == 0 {
+		return nil
+	}
+
+	key := object.PartitionKey()
+
+	for _, name := range names {
+		if _, isKey := key[name]; isKey || e.keyAttributes[name] {
+			return fmt.Errorf("%s: %w", name, ErrEncryptedKeyAttribute)
+		}
+	}
+
+	plaintextKey, encryptedKey, err := e.keyring.GenerateDataKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		value, ok := item[name]
+		if !ok {
+			continue
+		}
+
+		plaintext, err := json.Marshal(dynamoJSONValue(value))
+		if err != nil {
+			return err
+		}
+
+		additionalData, err := e.additionalData(name, item)
+		if err != nil {
+			return err
+		}
+
+		ciphertext, err := seal(plaintextKey, plaintext, additionalData)
+		if err != nil {
+			return err
+		}
+
+		item[name] = &types.AttributeValueMemberB{Value: ciphertext}
+	}
+
+	item[encryptedDataKeyAttribute] = &types.AttributeValueMemberB{Value: encryptedKey}
+
+	return nil
+}
+
+// decrypt restores the encrypted attributes of objectType in the item.
+func (e attributeEncryptor) decrypt(ctx context.Context, objectType reflect.Type, item map[string]types.AttributeValue) error {
+	encryptedKey, ok := item[encryptedDataKeyAttribute].(*types.AttributeValueMemberB)
+	if !ok {
+		return nil
+	}
+
+	plaintextKey, err := e.keyring.DecryptDataKey(ctx, encryptedKey.Value)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range EncryptedAttributes(objectType) {
+		ciphertext, ok := item[name].(*types.AttributeValueMemberB)
+		if !ok {
+			continue
+		}
+
+		additionalData, err := e.additionalData(name, item)
+		if err != nil {
+			return err
+		}
+
+		plaintext, err := open(plaintextKey, ciphertext.Value, additionalData)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		var jsonValue map[string]json.RawMessage
+
+		err = json.Unmarshal(plaintext, &jsonValue)
+		if err != nil {
+			return err
+		}
+
+		item[name], err = attributeValue(jsonValue)
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(item, encryptedDataKeyAttribute)
+
+	return nil
+}
+
+// additionalData is the attribute name and the values of the item's primary key attributes.
+func (e attributeEncryptor) additionalData(name string, item map[string]types.AttributeValue) ([]byte, error) {
+	keyNames := []string{e.index.PartitionKeyName()}
+	if e.index.SortKey != nil {
+		keyNames = append(keyNames, e.index.SortKeyName())
+	}
+
+	additionalData := []any{name}
+
+	for _, keyName := range keyNames {
+		value, ok := item[keyName]
+		if !ok {
+			return nil, fmt.Errorf("missing key attribute: %s", keyName)
+		}
+
+		additionalData = append(additionalData, dynamoJSONValue(value))
+	}
+
+	return json.Marshal(additionalData)
+}
+
+// EncryptedAttributes finds the attribute names of the fields tagged `encrypted:"true"`, including those of embedded
+// structs.
+func EncryptedAttributes(objectType reflect.Type) []string {
+	for objectType.Kind() == reflect.Pointer || objectType.Kind() == reflect.Slice {
+		objectType = objectType.Elem()
+	}
+
+	if objectType.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var names []string
+
+	for i := 0; i < objectType.NumField(); i++ {
+		field := objectType.Field(i)
+		name := attributeName(field)
+
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			names = append(names, EncryptedAttributes(field.Type)...)
+			continue
+		}
+
+		if field.Tag.Get(encryptedTag) != "true" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// attributeName is the name given by the field's dynamodbav tag, if any.
+func attributeName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("dynamodbav"), ",")
+
+	return name
+}
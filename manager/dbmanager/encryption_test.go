@@ -0,0 +1,232 @@
+package dbmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/joerdav/zapray"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPolicy struct {
+	PK     string
+	Holder string   `encrypted:"true"`
+	Scores []int    `dynamodbav:"Ratings" encrypted:"true"`
+	Tags   []string `dynamodbav:",stringset"`
+}
+
+func (p *testPolicy) PartitionKey() map[string]any {
+	return map[string]any{"PK": p.PK}
+}
+
+type testKeyedPolicy struct {
+	PK string `encrypted:"true"`
+}
+
+func (p *testKeyedPolicy) PartitionKey() map[string]any {
+	return map[string]any{"PK": p.PK}
+}
+
+// testReceipt is keyed as TestReception is - PartitionKey() omits the sort key.
+type testReceipt struct {
+	PK       string
+	Received string `encrypted:"true"`
+}
+
+func (r *testReceipt) PartitionKey() map[string]any {
+	return map[string]any{"PK": r.PK}
+}
+
+// fakeDynamoDB serves PutItem and GetItem on a table keyed by PK, so that DynamoManager can be tested end to end.
+type fakeDynamoDB struct {
+	mutex *sync.Mutex
+	items map[string]map[string]json.RawMessage
+}
+
+func newFakeDynamoDB() fakeDynamoDB {
+	return fakeDynamoDB{mutex: &sync.Mutex{}, items: map[string]map[string]json.RawMessage{}}
+}
+
+func (f fakeDynamoDB) Do(request *http.Request) (*http.Response, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var input struct {
+		Item map[string]json.RawMessage
+		Key  map[string]json.RawMessage
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	body := []byte("{}")
+
+	switch operation := request.Header.Get("X-Amz-Target"); {
+	case strings.HasSuffix(operation, ".PutItem"):
+		f.items[string(input.Item["PK"])] = input.Item
+
+	case strings.HasSuffix(operation, ".GetItem"):
+		if item, ok := f.items[string(input.Key["PK"])]; ok {
+			body, _ = json.Marshal(map[string]any{"Item": item})
+		}
+	}
+
+	response := http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.0"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+
+	return &response, nil
+}
+
+func newTestEncryptingManager(table fakeDynamoDB, secondaryIndexes ...Index) DynamoManager {
+	keyring, err := NewLocalKeyring(make([]byte, 32))
+	if err != nil {
+		panic(err)
+	}
+
+	cfg := aws.Config{Region: "eu-west-2", Credentials: aws.AnonymousCredentials{}, HTTPClient: table}
+	index := PrimaryIndex(StringAttribute("PK"), nil)
+
+	return NewDynamoManager(zapray.NewNop(), cfg, "Policies").WithEncryption(keyring, index, secondaryIndexes...)
+}
+
+func newTestEncryptor() attributeEncryptor {
+	keyring, err := NewLocalKeyring(make([]byte, 32))
+	if err != nil {
+		panic(err)
+	}
+
+	return *newAttributeEncryptor(keyring, PrimaryIndex(StringAttribute("PK"), nil), nil)
+}
+
+func TestEncryptedAttributes(t *testing.T) {
+	assert.Equal(t, []string{"Holder", "Ratings"}, EncryptedAttributes(reflect.TypeOf(&testPolicy{})))
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := context.Background()
+	encryptor := newTestEncryptor()
+	policy := testPolicy{PK: "policy1", Holder: "A Person", Scores: []int{1, 2}, Tags: []string{"a"}}
+
+	item, err := attributevalue.MarshalMap(&policy)
+	if err != nil {
+		panic(err)
+	}
+
+	err = encryptor.encrypt(ctx, &policy, item)
+	assert.Nil(t, err)
+	assert.IsType(t, &types.AttributeValueMemberB{}, item["Holder"])
+	assert.IsType(t, &types.AttributeValueMemberS{}, item["PK"])
+
+	err = encryptor.decrypt(ctx, reflect.TypeOf(&policy), item)
+	assert.Nil(t, err)
+
+	var decrypted testPolicy
+	err = attributevalue.UnmarshalMap(item, &decrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, policy, decrypted)
+}
+
+func TestEncryptKeyAttribute(t *testing.T) {
+	policy := testKeyedPolicy{PK: "policy1"}
+
+	item, err := attributevalue.MarshalMap(&policy)
+	if err != nil {
+		panic(err)
+	}
+
+	err = newTestEncryptor().encrypt(context.Background(), &policy, item)
+	assert.ErrorIs(t, err, ErrEncryptedKeyAttribute)
+}
+
+func TestDynamoJSON(t *testing.T) {
+	item, err := attributevalue.MarshalMap(&testPolicy{PK: "policy1", Holder: "A Person", Scores: []int{1, 2}, Tags: []string{"a"}})
+	if err != nil {
+		panic(err)
+	}
+
+	data, err := MarshalDynamoJSON(item)
+	assert.Nil(t, err)
+
+	restored, err := UnmarshalDynamoJSON(data)
+	assert.Nil(t, err)
+	assert.Equal(t, item, restored)
+}
+
+func TestManagerPutGetEncrypted(t *testing.T) {
+	ctx := context.Background()
+	table := newFakeDynamoDB()
+	manager := newTestEncryptingManager(table)
+
+	policy := testPolicy{PK: "policy1", Holder: "A Person", Scores: []int{1, 2}, Tags: []string{"a"}}
+	err := manager.Put(ctx, &policy)
+	assert.Nil(t, err)
+
+	stored := table.items[`{"S":"policy1"}`]
+	fmt.Println(string(stored["Holder"]))
+
+	assert.Contains(t, string(stored["Holder"]), `"B"`)
+	assert.NotContains(t, string(stored["Holder"]), "A Person")
+	assert.Contains(t, stored, encryptedDataKeyAttribute)
+
+	retrieved := testPolicy{PK: "policy1"}
+	err = manager.Get(ctx, &retrieved)
+	assert.Nil(t, err)
+	assert.Equal(t, policy, retrieved)
+}
+
+func TestManagerEncryptedValueBoundToItem(t *testing.T) {
+	ctx := context.Background()
+	table := newFakeDynamoDB()
+	manager := newTestEncryptingManager(table)
+
+	err := manager.Put(ctx, &testPolicy{PK: "policy1", Holder: "A Person"})
+	assert.Nil(t, err)
+
+	err = manager.Put(ctx, &testPolicy{PK: "policy2", Holder: "Someone Else"})
+	assert.Nil(t, err)
+
+	// copy the ciphertext and its data key to the other item
+	table.items[`{"S":"policy2"}`]["Holder"] = table.items[`{"S":"policy1"}`]["Holder"]
+	table.items[`{"S":"policy2"}`][encryptedDataKeyAttribute] = table.items[`{"S":"policy1"}`][encryptedDataKeyAttribute]
+
+	retrieved := testPolicy{PK: "policy2"}
+	err = manager.Get(ctx, &retrieved)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, "A Person", retrieved.Holder)
+}
+
+func TestManagerEncryptedKeyAttributes(t *testing.T) {
+	ctx := context.Background()
+	table := newFakeDynamoDB()
+
+	// the table sort key, omitted by PartitionKey()
+	keyring, _ := NewLocalKeyring(make([]byte, 32))
+	cfg := aws.Config{Region: "eu-west-2", Credentials: aws.AnonymousCredentials{}, HTTPClient: table}
+	manager := NewDynamoManager(zapray.NewNop(), cfg, "Receipts").WithEncryption(keyring, PrimaryIndex(StringAttribute("PK"), StringAttribute("Received")))
+
+	err := manager.Put(ctx, &testReceipt{PK: "a", Received: "2025-01-01"})
+	assert.ErrorIs(t, err, ErrEncryptedKeyAttribute)
+
+	// a secondary index key
+	manager = newTestEncryptingManager(table, GlobalIndex("HolderIndex", StringAttribute("Holder"), nil))
+
+	err = manager.Put(ctx, &testPolicy{PK: "policy1", Holder: "A Person"})
+	assert.ErrorIs(t, err, ErrEncryptedKeyAttribute)
+	assert.Equal(t, 0, len(table.items))
+}
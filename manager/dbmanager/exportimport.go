@@ -0,0 +1,392 @@
+package dbmanager
+
+// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Scan.html
+// https://docs.aws.amazon.com/amazondynamodb/latest/APIReference/API_BatchWriteItem.html
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/jsii-runtime-go"
+	"go.uber.org/zap"
+)
+
+// DynamoJSON preserves attribute types exactly - PlainJSON is easier to read, but binary values and sets become
+// strings and lists on import.
+type JSONFormat int
+
+const (
+	DynamoJSON JSONFormat = iota
+	PlainJSON
+)
+
+const (
+	batchWriteSize     = 25
+	batchWriteAttempts = 8
+	maxLineSize        = 1024 * 1024
+)
+
+var batchWriteBackoff = 50 * time.Millisecond
+
+type ImportOptions struct {
+	Format JSONFormat
+	// zero for no limit
+	ItemsPerSecond float64
+	// the number of lines imported by a previous run
+	ResumeAfter int64
+	// called with the number of lines imported, after each batch is written
+	Checkpoint func(lines int64) error
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Export writes every item in the table to writer, one per line, returning the number of items.
+func (m DynamoManager) Export(ctx context.Context, writer io.Writer, format JSONFormat) (int, error) {
+	m.logger.Debug("Export: ", zap.String("tableName", m.tableName))
+
+	params := dynamodb.ScanInput{TableName: jsii.String(m.tableName)}
+	count := 0
+
+	for {
+		response, err := m.dBClient.Scan(ctx, &params)
+		if err != nil {
+			m.logger.Error("Scan: ", zap.Error(err))
+			return count, err
+		}
+
+		err = writeItems(writer, format, response.Items)
+		if err != nil {
+			return count, err
+		}
+
+		count += len(response.Items)
+
+		if response.LastEvaluatedKey == nil {
+			return count, nil
+		}
+
+		params.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+}
+
+// ExportQuery writes the items found by the query to writer, one per line, returning the number of items.
+func (m DynamoManager) ExportQuery(ctx context.Context, writer io.Writer, format JSONFormat, index Index, condition KeyCondition) (int, error) {
+	m.logger.Debug("ExportQuery: ", zap.String("index", index.IndexName), zap.Any("condition", condition))
+
+	params, err := queryInput(m.tableName, index, condition)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+
+	for {
+		response, err := m.dBClient.Query(ctx, params)
+		if err != nil {
+			m.logger.Error("Query: ", zap.Error(err))
+			return count, err
+		}
+
+		items := response.Items
+		if condition.Limit > 0 && int32(count+len(items)) > condition.Limit {
+			items = items[:condition.Limit-int32(count)]
+		}
+
+		err = writeItems(writer, format, items)
+		if err != nil {
+			return count, err
+		}
+
+		count += len(items)
+
+		if response.LastEvaluatedKey == nil || (condition.Limit > 0 && int32(count) >= condition.Limit) {
+			return count, nil
+		}
+
+		params.ExclusiveStartKey = response.LastEvaluatedKey
+	}
+}
+
+// ImportTable is the table written by Import - DynamoManager, or MemoryDynamoManager for unit tests.
+type ImportTable interface {
+	batchWriteItems(ctx context.Context, items []map[string]types.AttributeValue) (unprocessed []map[string]types.AttributeValue, err error)
+}
+
+// Import batch-writes the items read from reader, one per line, to table, whose primary index is index. It returns
+// the number of lines imported, including any imported by a previous run. Where the lines of a batch repeat a key,
+// the last of them is written.
+func Import(ctx context.Context, table ImportTable, index Index, reader io.Reader, options ImportOptions) (int64, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	limiter := newRateLimiter(options.ItemsPerSecond)
+	batch := make([]map[string]types.AttributeValue, 0, batchWriteSize)
+	lines := int64(0)
+	committed := options.ResumeAfter
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		items := uniqueItems(index, batch)
+		limiter.wait(ctx, len(items))
+
+		err := batchWrite(ctx, table, items)
+		if err != nil {
+			return err
+		}
+
+		batch = batch[:0]
+		committed = lines
+
+		if options.Checkpoint != nil {
+			return options.Checkpoint(committed)
+		}
+
+		return nil
+	}
+
+	for scanner.Scan() {
+		lines++
+
+		if lines <= options.ResumeAfter || strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		item, err := readItem(scanner.Bytes(), options.Format)
+		if err != nil {
+			return committed, fmt.Errorf("line %d: %w", lines, err)
+		}
+
+		batch = append(batch, item)
+
+		if len(batch) == batchWriteSize {
+			err = flush()
+			if err != nil {
+				return committed, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return committed, err
+	}
+
+	err := flush()
+	if err != nil {
+		return committed, err
+	}
+
+	return max(lines, committed), nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (m DynamoManager) batchWriteItems(ctx context.Context, items []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	requests := make([]types.WriteRequest, len(items))
+
+	for i, item := range items {
+		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+	}
+
+	response, err := m.dBClient.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+		RequestItems: map[string][]types.WriteRequest{m.tableName: requests},
+	})
+
+	if err != nil {
+		m.logger.Error("BatchWriteItem: ", zap.Error(err))
+		return nil, err
+	}
+
+	var unprocessed []map[string]types.AttributeValue
+
+	for _, request := range response.UnprocessedItems[m.tableName] {
+		unprocessed = append(unprocessed, request.PutRequest.Item)
+	}
+
+	return unprocessed, nil
+}
+
+// batchWrite retries unprocessed items with exponential backoff.
+func batchWrite(ctx context.Context, table ImportTable, items []map[string]types.AttributeValue) error {
+	backoff := batchWriteBackoff
+
+	for attempt := 0; attempt < batchWriteAttempts; attempt++ {
+		unprocessed, err := table.batchWriteItems(ctx, items)
+		if err != nil {
+			return err
+		}
+
+		items = unprocessed
+		if len(items) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+
+	return fmt.Errorf("BatchWriteItem: %d items unprocessed after %d attempts", len(items), batchWriteAttempts)
+}
+
+// uniqueItems keeps the last of the items with the same key, as BatchWriteItem rejects a batch that repeats a key.
+// Items without a valid key are kept, for BatchWriteItem to reject.
+func uniqueItems(index Index, items []map[string]types.AttributeValue) []map[string]types.AttributeValue {
+	positions := map[string]int{}
+	unique := make([]map[string]types.AttributeValue, 0, len(items))
+
+	for _, item := range items {
+		key, err := indexKey(index, item, false)
+		if err != nil {
+			unique = append(unique, item)
+			continue
+		}
+
+		if position, ok := positions[key]; ok {
+			unique[position] = item
+			continue
+		}
+
+		positions[key] = len(unique)
+		unique = append(unique, item)
+	}
+
+	return unique
+}
+
+func writeItems(writer io.Writer, format JSONFormat, items []map[string]types.AttributeValue) error {
+	for _, item := range items {
+		line, err := writeItem(item, format)
+		if err != nil {
+			return err
+		}
+
+		_, err = writer.Write(append(line, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeItem(item map[string]types.AttributeValue, format JSONFormat) ([]byte, error) {
+	if format == DynamoJSON {
+		return MarshalDynamoJSON(item)
+	}
+
+	var object map[string]any
+
+	err := attributevalue.UnmarshalMapWithOptions(item, &object, func(o *attributevalue.DecoderOptions) { o.UseNumber = true })
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(toJSONNumbers(object))
+}
+
+func readItem(line []byte, format JSONFormat) (map[string]types.AttributeValue, error) {
+	if format == DynamoJSON {
+		return UnmarshalDynamoJSON(line)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(line)))
+	decoder.UseNumber()
+
+	var object map[string]any
+
+	err := decoder.Decode(&object)
+	if err != nil {
+		return nil, err
+	}
+
+	return attributevalue.MarshalMap(fromJSONNumbers(object))
+}
+
+// toJSONNumbers converts the attributevalue numbers in an unmarshalled item to encoding/json numbers.
+func toJSONNumbers(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for name, element := range value {
+			value[name] = toJSONNumbers(element)
+		}
+
+	case []any:
+		for i, element := range value {
+			value[i] = toJSONNumbers(element)
+		}
+
+	case []attributevalue.Number:
+		numbers := make([]json.Number, len(value))
+		for i, element := range value {
+			numbers[i] = json.Number(element)
+		}
+		return numbers
+
+	case attributevalue.Number:
+		return json.Number(value)
+	}
+
+	return value
+}
+
+// fromJSONNumbers converts the encoding/json numbers in a decoded object to attributevalue numbers.
+func fromJSONNumbers(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for name, element := range value {
+			value[name] = fromJSONNumbers(element)
+		}
+
+	case []any:
+		for i, element := range value {
+			value[i] = fromJSONNumbers(element)
+		}
+
+	case json.Number:
+		return attributevalue.Number(value)
+	}
+
+	return value
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type rateLimiter struct {
+	itemsPerSecond float64
+	start          time.Time
+	items          int
+}
+
+func newRateLimiter(itemsPerSecond float64) *rateLimiter {
+	return &rateLimiter{itemsPerSecond: itemsPerSecond, start: time.Now()}
+}
+
+// wait blocks until items more can be written without exceeding the rate.
+func (l *rateLimiter) wait(ctx context.Context, items int) {
+	if l.itemsPerSecond <= 0 {
+		return
+	}
+
+	due := l.start.Add(time.Duration(float64(l.items) / l.itemsPerSecond * float64(time.Second)))
+	l.items += items
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Until(due)):
+	}
+}
@@ -0,0 +1,171 @@
+package dbmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testImportTable counts the batches written to table, returning the first unprocessed items of the first batch as
+// unprocessed.
+type testImportTable struct {
+	table       ImportTable
+	unprocessed int
+	batches     *[][]map[string]types.AttributeValue
+}
+
+func newTestImportTable(table ImportTable, unprocessed int) testImportTable {
+	return testImportTable{table: table, unprocessed: unprocessed, batches: &[][]map[string]types.AttributeValue{}}
+}
+
+func (t testImportTable) batchWriteItems(ctx context.Context, items []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	*t.batches = append(*t.batches, items)
+
+	if len(*t.batches) == 1 && t.unprocessed > 0 {
+		_, err := t.table.batchWriteItems(ctx, items[t.unprocessed:])
+		return items[:t.unprocessed], err
+	}
+
+	return t.table.batchWriteItems(ctx, items)
+}
+
+func testImportLines(count int) string {
+	var lines strings.Builder
+
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&lines, "{\"PK\":\"a\",\"SK\":\"%02d\",\"Owner\":\"bob\"}\n", i)
+	}
+
+	return lines.String()
+}
+
+func TestPlainJSON(t *testing.T) {
+	item, err := attributevalue.MarshalMap(&testItem{PK: "a", SK: "1", Owner: "bob", Count: 12345678901, Expires: 17})
+	if err != nil {
+		panic(err)
+	}
+
+	line, err := writeItem(item, PlainJSON)
+	assert.Nil(t, err)
+	assert.Contains(t, string(line), `"Count":12345678901`)
+
+	restored, err := readItem(line, PlainJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, item, restored)
+}
+
+func TestDynamoJSONLine(t *testing.T) {
+	item, err := attributevalue.MarshalMap(&testItem{PK: "a", SK: "1", Owner: "bob"})
+	if err != nil {
+		panic(err)
+	}
+
+	line, err := writeItem(item, DynamoJSON)
+	assert.Nil(t, err)
+	assert.Contains(t, string(line), `"Owner":{"S":"bob"}`)
+
+	restored, err := readItem(line, DynamoJSON)
+	assert.Nil(t, err)
+	assert.Equal(t, item, restored)
+}
+
+func TestImportResume(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+	interrupted := errors.New("interrupted")
+
+	var checkpoints []int64
+
+	options := ImportOptions{Format: PlainJSON, Checkpoint: func(lines int64) error {
+		checkpoints = append(checkpoints, lines)
+		return interrupted
+	}}
+
+	// the first run stops after its first batch is written
+	lines, err := Import(ctx, manager, testIndex, strings.NewReader(testImportLines(30)), options)
+	assert.ErrorIs(t, err, interrupted)
+	assert.Equal(t, int64(25), lines)
+	assert.Equal(t, []int64{25}, checkpoints)
+
+	options.ResumeAfter = checkpoints[len(checkpoints)-1]
+	options.Checkpoint = func(lines int64) error {
+		checkpoints = append(checkpoints, lines)
+		return nil
+	}
+
+	table := newTestImportTable(manager, 0)
+
+	lines, err = Import(ctx, table, testIndex, strings.NewReader(testImportLines(30)), options)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(30), lines)
+	assert.Equal(t, []int64{25, 30}, checkpoints)
+
+	// only the lines after the checkpoint are written again
+	assert.Equal(t, 1, len(*table.batches))
+	assert.Equal(t, 5, len((*table.batches)[0]))
+
+	for i := 0; i < 30; i++ {
+		item := testItem{PK: "a", SK: fmt.Sprintf("%02d", i)}
+		assert.Nil(t, manager.Get(ctx, &item))
+		assert.Equal(t, "bob", item.Owner)
+	}
+}
+
+func TestImportDuplicateKeys(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	lines := "{\"PK\":\"a\",\"SK\":\"1\",\"Owner\":\"bob\"}\n" +
+		"{\"PK\":\"a\",\"SK\":\"2\",\"Owner\":\"bob\"}\n" +
+		"{\"PK\":\"a\",\"SK\":\"1\",\"Owner\":\"alice\"}\n"
+
+	count, err := Import(ctx, manager, testIndex, strings.NewReader(lines), ImportOptions{Format: PlainJSON})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), count)
+
+	// the last line with the key wins
+	item := testItem{PK: "a", SK: "1"}
+	assert.Nil(t, manager.Get(ctx, &item))
+	assert.Equal(t, "alice", item.Owner)
+}
+
+func TestImportUnprocessedItems(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+	table := newTestImportTable(manager, 3)
+
+	count, err := Import(ctx, table, testIndex, strings.NewReader(testImportLines(10)), ImportOptions{Format: PlainJSON})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10), count)
+
+	// the unprocessed items are retried alone
+	assert.Equal(t, 2, len(*table.batches))
+	assert.Equal(t, 3, len((*table.batches)[1]))
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, manager.Get(ctx, &testItem{PK: "a", SK: fmt.Sprintf("%02d", i)}))
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := newRateLimiter(20)
+	start := time.Now()
+
+	// the first batch is not delayed, and the second waits for the first at 20 items per second
+	limiter.wait(ctx, 5)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	limiter.wait(ctx, 5)
+	assert.GreaterOrEqual(t, time.Since(start), 240*time.Millisecond)
+
+	limiter.wait(ctx, 5)
+	assert.GreaterOrEqual(t, time.Since(start), 490*time.Millisecond)
+}
@@ -0,0 +1,105 @@
+package dbmanager
+
+// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/SecondaryIndexes.html
+
+import (
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+type IndexKind int
+
+const (
+	PrimaryIndexKind IndexKind = iota
+	GlobalIndexKind
+	LocalIndexKind
+)
+
+// Index describes the key schema of a table or one of its secondary indexes. It is declared once, next to the
+// DynamoAble type, and used both by the CDK table definition and by DynamoManager.Query.
+type Index struct {
+	Kind             IndexKind
+	IndexName        string
+	PartitionKey     *awsdynamodb.Attribute
+	SortKey          *awsdynamodb.Attribute
+	ProjectionType   awsdynamodb.ProjectionType
+	NonKeyAttributes []string
+}
+
+func PrimaryIndex(partitionKey *awsdynamodb.Attribute, sortKey *awsdynamodb.Attribute) Index {
+	return Index{Kind: PrimaryIndexKind, PartitionKey: partitionKey, SortKey: sortKey}
+}
+
+func GlobalIndex(indexName string, partitionKey *awsdynamodb.Attribute, sortKey *awsdynamodb.Attribute) Index {
+	return Index{Kind: GlobalIndexKind, IndexName: indexName, PartitionKey: partitionKey, SortKey: sortKey, ProjectionType: awsdynamodb.ProjectionType_ALL}
+}
+
+// a local index shares the partition key of its table
+func LocalIndex(indexName string, table Index, sortKey *awsdynamodb.Attribute) Index {
+	return Index{Kind: LocalIndexKind, IndexName: indexName, PartitionKey: table.PartitionKey, SortKey: sortKey, ProjectionType: awsdynamodb.ProjectionType_ALL}
+}
+
+func (i Index) WithProjection(projectionType awsdynamodb.ProjectionType, nonKeyAttributes ...string) Index {
+	i.ProjectionType = projectionType
+	i.NonKeyAttributes = nonKeyAttributes
+
+	return i
+}
+
+func (i Index) PartitionKeyName() string {
+	return *i.PartitionKey.Name
+}
+
+func (i Index) SortKeyName() string {
+	if i.SortKey == nil {
+		return ""
+	}
+
+	return *i.SortKey.Name
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// AddTo adds a secondary index to a CDK table - the primary index is set by the table's own props.
+func (i Index) AddTo(table awsdynamodb.Table) {
+	switch i.Kind {
+	case GlobalIndexKind:
+		table.AddGlobalSecondaryIndex(i.GlobalSecondaryIndexProps())
+
+	case LocalIndexKind:
+		table.AddLocalSecondaryIndex(i.LocalSecondaryIndexProps())
+	}
+}
+
+func (i Index) GlobalSecondaryIndexProps() *awsdynamodb.GlobalSecondaryIndexProps {
+	return &awsdynamodb.GlobalSecondaryIndexProps{
+		IndexName:        aws.String(i.IndexName),
+		PartitionKey:     i.PartitionKey,
+		SortKey:          i.SortKey,
+		ProjectionType:   i.ProjectionType,
+		NonKeyAttributes: i.nonKeyAttributes(),
+	}
+}
+
+func (i Index) LocalSecondaryIndexProps() *awsdynamodb.LocalSecondaryIndexProps {
+	return &awsdynamodb.LocalSecondaryIndexProps{
+		IndexName:        aws.String(i.IndexName),
+		SortKey:          i.SortKey,
+		ProjectionType:   i.ProjectionType,
+		NonKeyAttributes: i.nonKeyAttributes(),
+	}
+}
+
+func (i Index) nonKeyAttributes() *[]*string {
+	if len(i.NonKeyAttributes) == 0 {
+		return nil
+	}
+
+	attributes := make([]*string, len(i.NonKeyAttributes))
+
+	for n, attribute := range i.NonKeyAttributes {
+		attributes[n] = aws.String(attribute)
+	}
+
+	return &attributes
+}
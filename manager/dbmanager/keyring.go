@@ -0,0 +1,135 @@
+package dbmanager
+
+// https://docs.aws.amazon.com/kms/latest/developerguide/concepts.html#enveloping
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+)
+
+// Keyring provides the data keys for envelope encryption - each data key is stored, encrypted, with the item.
+type Keyring interface {
+	GenerateDataKey(ctx context.Context) (plaintextKey []byte, encryptedKey []byte, err error)
+	DecryptDataKey(ctx context.Context, encryptedKey []byte) ([]byte, error)
+}
+
+// KMSKeyring generates and decrypts AES-256 data keys with a KMS key.
+type KMSKeyring struct {
+	kmsClient *kms.Client
+	keyID     string
+}
+
+func NewKMSKeyring(cfg aws.Config, keyID string) KMSKeyring {
+	kmsClient := kms.NewFromConfig(cfg)
+
+	return KMSKeyring{kmsClient: kmsClient, keyID: keyID}
+}
+
+func (k KMSKeyring) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	response, err := k.kmsClient.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   aws.String(k.keyID),
+		KeySpec: kmstypes.DataKeySpecAes256,
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response.Plaintext, response.CiphertextBlob, nil
+}
+
+func (k KMSKeyring) DecryptDataKey(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	response, err := k.kmsClient.Decrypt(ctx, &kms.DecryptInput{
+		KeyId:          aws.String(k.keyID),
+		CiphertextBlob: encryptedKey,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Plaintext, nil
+}
+
+// LocalKeyring wraps data keys with a local AES-256 master key, for tests.
+type LocalKeyring struct {
+	masterKey []byte
+}
+
+func NewLocalKeyring(masterKey []byte) (LocalKeyring, error) {
+	if len(masterKey) != 32 {
+		return LocalKeyring{}, fmt.Errorf("the master key must be 32 bytes, not %d", len(masterKey))
+	}
+
+	return LocalKeyring{masterKey: masterKey}, nil
+}
+
+func (k LocalKeyring) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	plaintextKey := make([]byte, 32)
+
+	_, err := rand.Read(plaintextKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encryptedKey, err := seal(k.masterKey, plaintextKey, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return plaintextKey, encryptedKey, nil
+}
+
+func (k LocalKeyring) DecryptDataKey(ctx context.Context, encryptedKey []byte) ([]byte, error) {
+	return open(k.masterKey, encryptedKey, nil)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// seal encrypts with AES-GCM, prefixing the ciphertext with its nonce.
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,409 @@
+package dbmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/joerdav/zapray"
+	"go.uber.org/zap"
+)
+
+// MemoryDynamoManager is an in-memory DBManager for unit tests. It honours the table's key schema, conditions,
+// increments, TTL expiry and the ordering of queries.
+type MemoryDynamoManager struct {
+	logger       *zapray.Logger
+	index        Index
+	ttlAttribute string
+	mutex        *sync.Mutex
+	items        map[string]map[string]types.AttributeValue
+	Now          func() time.Time
+}
+
+var _ DBManager = DynamoManager{}
+var _ DBManager = MemoryDynamoManager{}
+
+var _ MigrationTable = DynamoManager{}
+var _ MigrationTable = MemoryDynamoManager{}
+
+var _ ImportTable = DynamoManager{}
+var _ ImportTable = MemoryDynamoManager{}
+
+// NewMemoryDynamoManager uses the primary index for the key schema - ttlAttribute may be empty.
+func NewMemoryDynamoManager(logger *zapray.Logger, index Index, ttlAttribute string) MemoryDynamoManager {
+	return MemoryDynamoManager{
+		logger:       logger,
+		index:        index,
+		ttlAttribute: ttlAttribute,
+		mutex:        &sync.Mutex{},
+		items:        map[string]map[string]types.AttributeValue{},
+		Now:          time.Now,
+	}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (m MemoryDynamoManager) TableIsAvailable(ctx context.Context) bool {
+	return true
+}
+
+func (m MemoryDynamoManager) Get(ctx context.Context, object DynamoAble) error {
+	m.logger.Debug("Get: ", zap.Any("key", object.PartitionKey()))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key, err := m.itemKey(getDBKey(object), true)
+	if err != nil {
+		return err
+	}
+
+	item := m.liveItem(key)
+	if item == nil {
+		return nil
+	}
+
+	return attributevalue.UnmarshalMap(item, &object)
+}
+
+func (m MemoryDynamoManager) Put(ctx context.Context, object DynamoAble) error {
+	m.logger.Debug("Put: ", zap.Any("object", object))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.put(object, nil)
+}
+
+func (m MemoryDynamoManager) PutIf(ctx context.Context, object DynamoAble, condition Condition) error {
+	m.logger.Debug("PutIf: ", zap.Any("object", object))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.put(object, &condition)
+}
+
+func (m MemoryDynamoManager) Increment(ctx context.Context, object DynamoAble, field string) error {
+	m.logger.Debug("Increment: ", zap.Any("object", object), zap.String("field", field))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key, err := m.itemKey(getDBKey(object), true)
+	if err != nil {
+		return err
+	}
+
+	// as DynamoManager, put the object if the item or its field does not exist
+	item := m.liveItem(key)
+	value, ok := item[field].(*types.AttributeValueMemberN)
+	if !ok {
+		return m.put(object, nil)
+	}
+
+	count, err := strconv.ParseInt(value.Value, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	item[field] = &types.AttributeValueMemberN{Value: strconv.FormatInt(count+1, 10)}
+
+	return nil
+}
+
+func (m MemoryDynamoManager) Add(ctx context.Context, object DynamoAble, field string, delta int64) error {
+	m.logger.Debug("Add: ", zap.Any("object", object), zap.String("field", field), zap.Int64("delta", delta))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	dBKey := getDBKey(object)
+
+	key, err := m.itemKey(dBKey, true)
+	if err != nil {
+		return err
+	}
+
+	// as DynamoDB ADD, create the item with its key attributes, and treat a missing field as 0
+	item := m.liveItem(key)
+	if item == nil {
+		item = dBKey
+		m.items[key] = item
+	}
+
+	var count int64
+
+	if value, ok := item[field].(*types.AttributeValueMemberN); ok {
+		count, err = strconv.ParseInt(value.Value, 10, 64)
+		if err != nil {
+			return err
+		}
+	} else if item[field] != nil {
+		return fmt.Errorf("an operand in the update expression has an incorrect data type: %s", field)
+	}
+
+	item[field] = &types.AttributeValueMemberN{Value: strconv.FormatInt(count+delta, 10)}
+
+	return nil
+}
+
+func (m MemoryDynamoManager) Query(ctx context.Context, index Index, condition KeyCondition, items any) error {
+	m.logger.Debug("Query: ", zap.String("index", index.IndexName), zap.Any("condition", condition))
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// validates the condition as DynamoManager does
+	params, err := queryInput("", index, condition)
+	if err != nil {
+		return err
+	}
+
+	partitionValue := params.ExpressionAttributeValues[":pk"]
+
+	var found []map[string]types.AttributeValue
+
+	for key := range m.items {
+		item := m.liveItem(key)
+		if item == nil {
+			continue
+		}
+
+		// secondary indexes are sparse
+		if comparison, ok := compareAttributeValues(item[index.PartitionKeyName()], partitionValue); !ok || comparison != 0 {
+			continue
+		}
+
+		if condition.SortOperator != "" && !sortKeyMatches(item[index.SortKeyName()], params.ExpressionAttributeValues, condition.SortOperator) {
+			continue
+		}
+
+		found = append(found, item)
+	}
+
+	if index.SortKey != nil {
+		sort.SliceStable(found, func(i, j int) bool {
+			comparison, _ := compareAttributeValues(found[i][index.SortKeyName()], found[j][index.SortKeyName()])
+
+			if condition.Descending {
+				return comparison > 0
+			}
+
+			return comparison < 0
+		})
+	}
+
+	if condition.Limit > 0 && int32(len(found)) > condition.Limit {
+		found = found[:condition.Limit]
+	}
+
+	return attributevalue.UnmarshalListOfMaps(found, items)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (m MemoryDynamoManager) put(object DynamoAble, condition *Condition) error {
+	item, err := attributevalue.MarshalMap(object)
+	if err != nil {
+		panic(err)
+	}
+
+	return m.putItem(item, condition)
+}
+
+func (m MemoryDynamoManager) putItemIf(ctx context.Context, item map[string]types.AttributeValue, condition Condition) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.putItem(maps.Clone(item), &condition)
+}
+
+// scanSegment returns every live item of the segment - chosen by the hash of its key - in key order, in a single page.
+func (m MemoryDynamoManager) scanSegment(ctx context.Context, segment int, totalSegments int, startKey map[string]types.AttributeValue) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	keys := slices.Sorted(maps.Keys(m.items))
+
+	var items []map[string]types.AttributeValue
+
+	for _, key := range keys {
+		item := m.liveItem(key)
+		if item == nil || keySegment(key, totalSegments) != segment {
+			continue
+		}
+
+		items = append(items, maps.Clone(item))
+	}
+
+	return items, nil, nil
+}
+
+// batchWriteItems puts every item, rejecting the batch as BatchWriteItem does if two items have the same key.
+func (m MemoryDynamoManager) batchWriteItems(ctx context.Context, items []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(items) > batchWriteSize {
+		return nil, fmt.Errorf("too many items in the batch: %d", len(items))
+	}
+
+	keys := map[string]bool{}
+
+	for _, item := range items {
+		key, err := m.itemKey(item, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if keys[key] {
+			return nil, fmt.Errorf("provided list of item keys contains duplicates: %s", key)
+		}
+
+		keys[key] = true
+	}
+
+	for _, item := range items {
+		err := m.putItem(maps.Clone(item), nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func keySegment(key string, totalSegments int) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+
+	return int(hash.Sum32() % uint32(totalSegments))
+}
+
+func (m MemoryDynamoManager) putItem(item map[string]types.AttributeValue, condition *Condition) error {
+	key, err := m.itemKey(item, false)
+	if err != nil {
+		return err
+	}
+
+	if condition != nil && !condition.Evaluate(m.liveItem(key)) {
+		return ErrConditionFailed
+	}
+
+	m.items[key] = item
+
+	return nil
+}
+
+// itemKey finds the key attributes in the item - if exact, the item must have no other attributes, as for GetItem.
+func (m MemoryDynamoManager) itemKey(item map[string]types.AttributeValue, exact bool) (string, error) {
+	return indexKey(m.index, item, exact)
+}
+
+// indexKey encodes the values of the index's key attributes in the item as a string.
+func indexKey(index Index, item map[string]types.AttributeValue, exact bool) (string, error) {
+	names := []string{index.PartitionKeyName()}
+	if index.SortKey != nil {
+		names = append(names, index.SortKeyName())
+	}
+
+	if exact && len(item) != len(names) {
+		return "", fmt.Errorf("the provided key element does not match the schema: %v", names)
+	}
+
+	key := ""
+
+	for _, name := range names {
+		switch value := item[name].(type) {
+		case *types.AttributeValueMemberS:
+			if value.Value == "" {
+				return "", fmt.Errorf("empty key attribute: %s", name)
+			}
+			key += "S:" + value.Value + "|"
+		case *types.AttributeValueMemberN:
+			key += "N:" + value.Value + "|"
+		case *types.AttributeValueMemberB:
+			key += "B:" + string(value.Value) + "|"
+		default:
+			return "", fmt.Errorf("missing or invalid key attribute: %s", name)
+		}
+	}
+
+	return key, nil
+}
+
+// liveItem returns nil for an absent or expired item.
+func (m MemoryDynamoManager) liveItem(key string) map[string]types.AttributeValue {
+	item, ok := m.items[key]
+	if !ok {
+		return nil
+	}
+
+	if m.ttlAttribute == "" {
+		return item
+	}
+
+	expiry, ok := item[m.ttlAttribute].(*types.AttributeValueMemberN)
+	if !ok {
+		return item
+	}
+
+	// as DynamoDB, ignore expiry times more than five years in the past
+	seconds, err := strconv.ParseInt(expiry.Value, 10, 64)
+	if err == nil && seconds < m.Now().Unix() && seconds > m.Now().AddDate(-5, 0, 0).Unix() {
+		return nil
+	}
+
+	return item
+}
+
+func sortKeyMatches(value types.AttributeValue, values map[string]types.AttributeValue, operator string) bool {
+	switch operator {
+	case "BETWEEN":
+		lower, lowerOk := compareAttributeValues(value, values[":sk0"])
+		upper, upperOk := compareAttributeValues(value, values[":sk1"])
+		return lowerOk && upperOk && lower >= 0 && upper <= 0
+
+	case "begins_with":
+		switch value := value.(type) {
+		case *types.AttributeValueMemberS:
+			prefix, ok := values[":sk"].(*types.AttributeValueMemberS)
+			return ok && strings.HasPrefix(value.Value, prefix.Value)
+		case *types.AttributeValueMemberB:
+			prefix, ok := values[":sk"].(*types.AttributeValueMemberB)
+			return ok && bytes.HasPrefix(value.Value, prefix.Value)
+		}
+		return false
+	}
+
+	comparison, ok := compareAttributeValues(value, values[":sk"])
+	if !ok {
+		return false
+	}
+
+	switch operator {
+	case "=":
+		return comparison == 0
+	case "<":
+		return comparison < 0
+	case "<=":
+		return comparison <= 0
+	case ">":
+		return comparison > 0
+	case ">=":
+		return comparison >= 0
+	}
+
+	return false
+}
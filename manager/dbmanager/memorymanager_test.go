@@ -0,0 +1,111 @@
+package dbmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joerdav/zapray"
+	"github.com/stretchr/testify/assert"
+)
+
+type testItem struct {
+	PK      string
+	SK      string
+	Owner   string
+	Count   int
+	Expires int64
+}
+
+func (i *testItem) PartitionKey() map[string]any {
+	return map[string]any{"PK": i.PK, "SK": i.SK}
+}
+
+var testIndex = PrimaryIndex(StringAttribute("PK"), StringAttribute("SK"))
+var testOwnerIndex = GlobalIndex("OwnerIndex", StringAttribute("Owner"), StringAttribute("SK"))
+
+func newTestManager() MemoryDynamoManager {
+	return NewMemoryDynamoManager(zapray.NewNop(), testIndex, "Expires")
+}
+
+func TestMemoryPutGet(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	err := manager.Put(ctx, &testItem{PK: "a", SK: "1", Owner: "bob"})
+	assert.Nil(t, err)
+
+	item := testItem{PK: "a", SK: "1"}
+	err = manager.Get(ctx, &item)
+	assert.Nil(t, err)
+	assert.Equal(t, "bob", item.Owner)
+
+	err = manager.Put(ctx, &testItem{PK: "a"})
+	assert.NotNil(t, err)
+}
+
+func TestMemoryPutIf(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	err := manager.PutIf(ctx, &testItem{PK: "a", SK: "1", Count: 1}, AttributeNotExists("PK"))
+	assert.Nil(t, err)
+
+	err = manager.PutIf(ctx, &testItem{PK: "a", SK: "1", Count: 2}, AttributeNotExists("PK"))
+	assert.ErrorIs(t, err, ErrConditionFailed)
+
+	err = manager.PutIf(ctx, &testItem{PK: "a", SK: "1", Count: 2}, Equal("Count", 1))
+	assert.Nil(t, err)
+}
+
+func TestMemoryIncrement(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	for i := 0; i < 3; i++ {
+		err := manager.Increment(ctx, &testItem{PK: "a", SK: "1", Count: 1}, "Count")
+		assert.Nil(t, err)
+	}
+
+	item := testItem{PK: "a", SK: "1"}
+	err := manager.Get(ctx, &item)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, item.Count)
+}
+
+func TestMemoryTTL(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	err := manager.Put(ctx, &testItem{PK: "a", SK: "1", Owner: "bob", Expires: time.Now().Add(-time.Minute).Unix()})
+	assert.Nil(t, err)
+
+	item := testItem{PK: "a", SK: "1"}
+	err = manager.Get(ctx, &item)
+	assert.Nil(t, err)
+	assert.Equal(t, "", item.Owner)
+}
+
+func TestMemoryQuery(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestManager()
+
+	for _, sk := range []string{"2", "3", "1"} {
+		err := manager.Put(ctx, &testItem{PK: "p" + sk, SK: sk, Owner: "bob"})
+		assert.Nil(t, err)
+	}
+
+	err := manager.Put(ctx, &testItem{PK: "p4", SK: "4", Owner: "alice"})
+	assert.Nil(t, err)
+
+	var items []testItem
+	err = manager.Query(ctx, testOwnerIndex, KeyCondition{PartitionValue: "bob", Descending: true}, &items)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"3", "2", "1"}, []string{items[0].SK, items[1].SK, items[2].SK})
+
+	items = nil
+	err = manager.Query(ctx, testOwnerIndex, KeyCondition{PartitionValue: "bob", SortOperator: ">=", SortValues: []any{"2"}, Limit: 1}, &items)
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "2", items[0].SK)
+}
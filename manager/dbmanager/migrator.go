@@ -0,0 +1,298 @@
+package dbmanager
+
+// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Scan.html#Scan.ParallelScan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/joerdav/zapray"
+	"go.uber.org/zap"
+)
+
+const (
+	MigrationRunning   = "running"
+	MigrationCompleted = "completed"
+	MigrationFailed    = "failed"
+)
+
+// Transform migrates an item from one schema version to the next - it may modify the item in place.
+type Transform func(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error)
+
+// MigrationRecord is an item in the migration history table - the "run" entry records the whole migration, and
+// each "segment#N" entry is the checkpoint of a scan segment.
+type MigrationRecord struct {
+	MigrationID      string
+	Entry            string
+	Status           string
+	DryRun           bool
+	StartedAt        string
+	CompletedAt      string
+	Scanned          int
+	Migrated         int
+	Skipped          int
+	Conflicts        int
+	LastEvaluatedKey string
+}
+
+func (r *MigrationRecord) PartitionKey() map[string]any {
+	return map[string]any{"MigrationID": r.MigrationID, "Entry": r.Entry}
+}
+
+// MigrationHistoryIndex is the key schema of the migration history table.
+func MigrationHistoryIndex() Index {
+	return PrimaryIndex(StringAttribute("MigrationID"), StringAttribute("Entry"))
+}
+
+// MigrationTable is the table scanned and updated by a Migrator - DynamoManager, or MemoryDynamoManager for unit
+// tests.
+type MigrationTable interface {
+	scanSegment(ctx context.Context, segment int, totalSegments int, startKey map[string]types.AttributeValue) (items []map[string]types.AttributeValue, lastKey map[string]types.AttributeValue, err error)
+	putItemIf(ctx context.Context, item map[string]types.AttributeValue, condition Condition) error
+}
+
+// Migrator scans a table in parallel segments, applying the transforms registered for each item's schema version
+// until it reaches the latest version, and writes the item back only if its version has not changed in the meantime.
+// Progress is checkpointed in the history table, so that an interrupted migration resumes where it stopped.
+type Migrator struct {
+	logger           *zapray.Logger
+	table            MigrationTable
+	index            Index
+	history          DBManager
+	MigrationID      string
+	VersionAttribute string
+	Segments         int
+	DryRun           bool
+	transforms       map[int]Transform
+}
+
+// NewMigrator migrates the items of table, whose primary index is index, recording its progress in history.
+func NewMigrator(logger *zapray.Logger, table MigrationTable, index Index, history DBManager, migrationID string, versionAttribute string, segments int) Migrator {
+	return Migrator{
+		logger:           logger,
+		table:            table,
+		index:            index,
+		history:          history,
+		MigrationID:      migrationID,
+		VersionAttribute: versionAttribute,
+		Segments:         max(segments, 1),
+		transforms:       map[int]Transform{},
+	}
+}
+
+// Register the transform from fromVersion to fromVersion + 1 - items without a version attribute are version 0.
+func (m Migrator) Register(fromVersion int, transform Transform) {
+	m.transforms[fromVersion] = transform
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (m Migrator) Run(ctx context.Context) (MigrationRecord, error) {
+	m.logger.Info("Migrator.Run: ", zap.String("migrationID", m.MigrationID), zap.Bool("dryRun", m.DryRun))
+
+	run := MigrationRecord{MigrationID: m.MigrationID, Entry: m.entry("run")}
+
+	err := m.history.Get(ctx, &run)
+	if err != nil {
+		return run, err
+	}
+
+	if run.Status == MigrationCompleted {
+		m.logger.Info("Migration has already been completed", zap.String("migrationID", m.MigrationID))
+		return run, nil
+	}
+
+	run = MigrationRecord{MigrationID: m.MigrationID, Entry: run.Entry, Status: MigrationRunning, DryRun: m.DryRun, StartedAt: timestamp()}
+
+	err = m.history.Put(ctx, &run)
+	if err != nil {
+		return run, err
+	}
+
+	segments := make([]MigrationRecord, m.Segments)
+	errs := make([]error, m.Segments)
+
+	var wait sync.WaitGroup
+
+	for segment := 0; segment < m.Segments; segment++ {
+		wait.Add(1)
+
+		go func(segment int) {
+			defer wait.Done()
+			segments[segment], errs[segment] = m.runSegment(ctx, segment)
+		}(segment)
+	}
+
+	wait.Wait()
+
+	for _, record := range segments {
+		run.Scanned += record.Scanned
+		run.Migrated += record.Migrated
+		run.Skipped += record.Skipped
+		run.Conflicts += record.Conflicts
+	}
+
+	run.Status = MigrationCompleted
+	run.CompletedAt = timestamp()
+
+	err = errors.Join(errs...)
+	if err != nil {
+		run.Status = MigrationFailed
+	}
+
+	return run, errors.Join(err, m.history.Put(ctx, &run))
+}
+
+func (m Migrator) runSegment(ctx context.Context, segment int) (MigrationRecord, error) {
+	checkpoint := MigrationRecord{MigrationID: m.MigrationID, Entry: m.entry(fmt.Sprintf("segment#%d", segment))}
+
+	err := m.history.Get(ctx, &checkpoint)
+	if err != nil {
+		return checkpoint, err
+	}
+
+	if checkpoint.Status == MigrationCompleted {
+		return checkpoint, nil
+	}
+
+	var startKey map[string]types.AttributeValue
+
+	if checkpoint.LastEvaluatedKey != "" {
+		startKey, err = UnmarshalDynamoJSON([]byte(checkpoint.LastEvaluatedKey))
+		if err != nil {
+			return checkpoint, err
+		}
+	}
+
+	checkpoint.Status = MigrationRunning
+
+	for {
+		items, lastKey, err := m.table.scanSegment(ctx, segment, m.Segments, startKey)
+		if err != nil {
+			m.logger.Error("Scan: ", zap.Int("segment", segment), zap.Error(err))
+			return checkpoint, err
+		}
+
+		for _, item := range items {
+			err = m.migrate(ctx, item, &checkpoint)
+			if err != nil {
+				return checkpoint, err
+			}
+		}
+
+		if lastKey == nil {
+			checkpoint.Status = MigrationCompleted
+			checkpoint.LastEvaluatedKey = ""
+
+			return checkpoint, m.history.Put(ctx, &checkpoint)
+		}
+
+		key, err := MarshalDynamoJSON(lastKey)
+		if err != nil {
+			return checkpoint, err
+		}
+
+		checkpoint.LastEvaluatedKey = string(key)
+
+		err = m.history.Put(ctx, &checkpoint)
+		if err != nil {
+			return checkpoint, err
+		}
+
+		startKey = lastKey
+	}
+}
+
+func (m Migrator) migrate(ctx context.Context, item map[string]types.AttributeValue, checkpoint *MigrationRecord) error {
+	checkpoint.Scanned++
+
+	version, migrated, err := m.transform(item)
+	if err != nil {
+		return err
+	}
+
+	if migrated == nil {
+		checkpoint.Skipped++
+		return nil
+	}
+
+	if m.DryRun {
+		checkpoint.Migrated++
+		return nil
+	}
+
+	// the item must still exist, at the version that was transformed - version 0 may be absent or 0
+	condition := And(AttributeExists(m.index.PartitionKeyName()), Equal(m.VersionAttribute, version))
+	if version == 0 {
+		condition = And(AttributeExists(m.index.PartitionKeyName()), Or(AttributeNotExists(m.VersionAttribute), Equal(m.VersionAttribute, 0)))
+	}
+
+	err = m.table.putItemIf(ctx, migrated, condition)
+	if errors.Is(err, ErrConditionFailed) {
+		checkpoint.Conflicts++
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	checkpoint.Migrated++
+
+	return nil
+}
+
+// transform applies the registered transforms to the item, returning its original version, and the migrated item,
+// which is nil if there is no transform for its version.
+func (m Migrator) transform(item map[string]types.AttributeValue) (int, map[string]types.AttributeValue, error) {
+	version := 0
+
+	if value, ok := item[m.VersionAttribute].(*types.AttributeValueMemberN); ok {
+		var err error
+
+		version, err = strconv.Atoi(value.Value)
+		if err != nil {
+			return 0, nil, fmt.Errorf("%s: %w", m.VersionAttribute, err)
+		}
+	}
+
+	migrated := item
+
+	for next := version; ; next++ {
+		transform, ok := m.transforms[next]
+		if !ok {
+			if next == version {
+				return version, nil, nil
+			}
+
+			migrated[m.VersionAttribute] = &types.AttributeValueMemberN{Value: strconv.Itoa(next)}
+
+			return version, migrated, nil
+		}
+
+		var err error
+
+		migrated, err = transform(migrated)
+		if err != nil {
+			return version, nil, fmt.Errorf("transform from version %d: %w", next, err)
+		}
+	}
+}
+
+// dry runs are recorded separately, and always start afresh
+func (m Migrator) entry(name string) string {
+	if m.DryRun {
+		return fmt.Sprintf("dryrun/%s/%d", name, time.Now().UnixNano())
+	}
+
+	return name
+}
+
+func timestamp() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,140 @@
+package dbmanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/joerdav/zapray"
+	"github.com/stretchr/testify/assert"
+)
+
+type testVersionedItem struct {
+	PK         string
+	SK         string
+	Subscriber string `dynamodbav:",omitempty"`
+	Count      *int   `dynamodbav:",omitempty"`
+	Version    *int   `dynamodbav:",omitempty"`
+}
+
+func (i *testVersionedItem) PartitionKey() map[string]any {
+	return map[string]any{"PK": i.PK, "SK": i.SK}
+}
+
+func newTestMigrator(table MemoryDynamoManager, dryRun bool) Migrator {
+	migrator := NewMigrator(zapray.NewNop(), table, testIndex, NewMemoryDynamoManager(zapray.NewNop(), MigrationHistoryIndex(), ""), "subscriber", "Version", 4)
+	migrator.DryRun = dryRun
+
+	migrator.Register(0, func(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+		item["Subscriber"] = &types.AttributeValueMemberS{Value: "unknown"}
+		return item, nil
+	})
+
+	migrator.Register(1, func(item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+		item["Count"] = &types.AttributeValueMemberN{Value: "0"}
+		return item, nil
+	})
+
+	return migrator
+}
+
+func putTestVersionedItems(table MemoryDynamoManager) {
+	versions := map[string]*int{"absent": nil, "zero": new(int), "one": new(int), "two": new(int)}
+	*versions["one"] = 1
+	*versions["two"] = 2
+
+	for pk, version := range versions {
+		err := table.Put(context.Background(), &testVersionedItem{PK: pk, SK: "1", Version: version})
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestMigratorTransform(t *testing.T) {
+	migrator := newTestMigrator(newTestManager(), false)
+
+	version, migrated, err := migrator.transform(map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "a"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, version)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "2"}, migrated["Version"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "unknown"}, migrated["Subscriber"])
+
+	version, migrated, err = migrator.transform(map[string]types.AttributeValue{"Version": &types.AttributeValueMemberN{Value: "2"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, version)
+	assert.Nil(t, migrated)
+}
+
+func TestMigratorRun(t *testing.T) {
+	ctx := context.Background()
+	table := newTestManager()
+	putTestVersionedItems(table)
+
+	migrator := newTestMigrator(table, false)
+
+	run, err := migrator.Run(ctx)
+	fmt.Printf("%+v\n", run)
+
+	assert.Nil(t, err)
+	assert.Equal(t, MigrationCompleted, run.Status)
+	assert.Equal(t, 4, run.Scanned)
+	assert.Equal(t, 3, run.Migrated)
+	assert.Equal(t, 1, run.Skipped)
+	assert.Equal(t, 0, run.Conflicts)
+
+	for _, pk := range []string{"absent", "zero", "one", "two"} {
+		item := testVersionedItem{PK: pk, SK: "1"}
+		err = table.Get(ctx, &item)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, *item.Version, pk)
+	}
+
+	// a completed migration is not run again
+	again, err := migrator.Run(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, run.StartedAt, again.StartedAt)
+}
+
+func TestMigratorRunDryRun(t *testing.T) {
+	ctx := context.Background()
+	table := newTestManager()
+	putTestVersionedItems(table)
+
+	run, err := newTestMigrator(table, true).Run(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, run.Migrated)
+
+	item := testVersionedItem{PK: "absent", SK: "1"}
+	err = table.Get(ctx, &item)
+	assert.Nil(t, err)
+	assert.Nil(t, item.Version)
+}
+
+func TestMigratorConflicts(t *testing.T) {
+	ctx := context.Background()
+	table := newTestManager()
+	migrator := newTestMigrator(table, false)
+
+	// deleted between the scan and the put - the item is not restored
+	var checkpoint MigrationRecord
+	err := migrator.migrate(ctx, map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "deleted"}, "SK": &types.AttributeValueMemberS{Value: "1"}}, &checkpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, checkpoint.Conflicts)
+
+	item := testVersionedItem{PK: "deleted", SK: "1"}
+	err = table.Get(ctx, &item)
+	assert.Nil(t, err)
+	assert.Nil(t, item.Version)
+
+	// updated between the scan and the put
+	version := 1
+	err = table.Put(ctx, &testVersionedItem{PK: "updated", SK: "1", Version: &version})
+	assert.Nil(t, err)
+
+	err = migrator.migrate(ctx, map[string]types.AttributeValue{"PK": &types.AttributeValueMemberS{Value: "updated"}, "SK": &types.AttributeValueMemberS{Value: "1"}}, &checkpoint)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, checkpoint.Conflicts)
+	assert.Equal(t, 0, checkpoint.Migrated)
+}
@@ -0,0 +1,130 @@
+package dbmanager
+
+// https://docs.aws.amazon.com/amazondynamodb/latest/developerguide/bp-partition-key-sharding.html
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+type ShardSelection int
+
+const (
+	RandomShard ShardSelection = iota
+	HashedShard
+)
+
+// ShardedCounter spreads the increments of a hot counter over a number of shard items, whose partition keys are
+// the counter ID with a shard suffix. On a table with a sort key, every shard has the SortValue.
+type ShardedCounter struct {
+	manager   DBManager
+	index     Index
+	CounterID string
+	SortValue any
+	Field     string
+	Shards    int
+	Selection ShardSelection
+}
+
+func NewShardedCounter(manager DBManager, index Index, counterID string, field string, shards int, selection ShardSelection) ShardedCounter {
+	return ShardedCounter{manager: manager, index: index, CounterID: counterID, SortValue: counterID, Field: field, Shards: max(shards, 1), Selection: selection}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Increment increments one shard - hashKey selects the shard for HashedShard selection, and is ignored otherwise.
+func (c ShardedCounter) Increment(ctx context.Context, hashKey string) error {
+	shard := c.shard(c.selectShard(hashKey))
+
+	return c.manager.Add(ctx, &shard, c.Field, 1)
+}
+
+// Value is the sum of the shards.
+func (c ShardedCounter) Value(ctx context.Context) (int64, error) {
+	var total int64
+
+	for n := 0; n < c.Shards; n++ {
+		shard := c.shard(n)
+
+		err := c.manager.Get(ctx, &shard)
+		if err != nil {
+			return 0, err
+		}
+
+		total += shard.count
+	}
+
+	return total, nil
+}
+
+func (c ShardedCounter) ShardKey(n int) map[string]any {
+	key := map[string]any{c.index.PartitionKeyName(): fmt.Sprintf("%s#%d", c.CounterID, n)}
+
+	if c.index.SortKey != nil {
+		key[c.index.SortKeyName()] = c.SortValue
+	}
+
+	return key
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (c ShardedCounter) selectShard(hashKey string) int {
+	if c.Selection == HashedShard {
+		hash := fnv.New32a()
+		hash.Write([]byte(hashKey))
+
+		return int(hash.Sum32() % uint32(c.Shards))
+	}
+
+	return rand.IntN(c.Shards)
+}
+
+func (c ShardedCounter) shard(n int) counterShard {
+	return counterShard{key: c.ShardKey(n), field: c.Field}
+}
+
+// counterShard is the DynamoAble item of one shard.
+type counterShard struct {
+	key   map[string]any
+	field string
+	count int64
+}
+
+func (s *counterShard) PartitionKey() map[string]any {
+	return s.key
+}
+
+func (s *counterShard) MarshalDynamoDBAttributeValue() (types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(s.key)
+	if err != nil {
+		return nil, err
+	}
+
+	item[s.field] = &types.AttributeValueMemberN{Value: strconv.FormatInt(s.count, 10)}
+
+	return &types.AttributeValueMemberM{Value: item}, nil
+}
+
+func (s *counterShard) UnmarshalDynamoDBAttributeValue(value types.AttributeValue) error {
+	item, ok := value.(*types.AttributeValueMemberM)
+	if !ok {
+		return fmt.Errorf("counter shard is not a map")
+	}
+
+	count, ok := item.Value[s.field].(*types.AttributeValueMemberN)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	s.count, err = strconv.ParseInt(count.Value, 10, 64)
+
+	return err
+}
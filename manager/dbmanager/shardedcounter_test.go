@@ -0,0 +1,66 @@
+package dbmanager
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardedCounter(t *testing.T) {
+	ctx := context.Background()
+	counter := NewShardedCounter(newTestManager(), testIndex, "/test1/ok1", "Hits", 4, RandomShard)
+
+	for i := 0; i < 20; i++ {
+		err := counter.Increment(ctx, "")
+		assert.Nil(t, err)
+	}
+
+	value, err := counter.Value(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(20), value)
+}
+
+func TestShardedCounterHashed(t *testing.T) {
+	counter := NewShardedCounter(newTestManager(), testIndex, "/test1/ok1", "Hits", 8, HashedShard)
+
+	assert.Equal(t, counter.selectShard("client1"), counter.selectShard("client1"))
+	assert.Equal(t, "/test1/ok1#3", counter.ShardKey(3)["PK"])
+}
+
+func TestShardedCounterConcurrent(t *testing.T) {
+	ctx := context.Background()
+	counter := NewShardedCounter(newTestManager(), testIndex, "/test1/ok1", "Hits", 2, RandomShard)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+				err := counter.Increment(ctx, "")
+				assert.Nil(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	value, err := counter.Value(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), value)
+}
+
+func TestAddInput(t *testing.T) {
+	shard := NewShardedCounter(newTestManager(), testIndex, "/test1/ok1", "Hits", 2, RandomShard).shard(1)
+	input := addInput("table", &shard, "Hits", 1)
+
+	// a single ADD creates the item - there is no read-modify-write or put fallback to race
+	assert.Equal(t, "ADD #field :delta", *input.UpdateExpression)
+	assert.Nil(t, input.ConditionExpression)
+	assert.Equal(t, 2, len(input.Key))
+}
@@ -0,0 +1,175 @@
+package envelope
+
+// https://www.enterpriseintegrationpatterns.com/patterns/messaging/StoreInLibrary.html
+//
+// Bodies are only offloaded by a Publisher WithClaimCheck - SNSManager and SQSManager send bodies as given, and
+// fail on bodies over 256 KB.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/s3manager"
+)
+
+// ClaimCheckAttribute is the location of an offloaded body - its presence signals a claim-check message.
+const ClaimCheckAttribute = "ClaimCheck"
+
+// ClaimCheckThreshold is the default size above which bodies are offloaded, leaving room below the 256 KB limit for
+// attributes and SNS notification wrappers.
+const ClaimCheckThreshold = 200 * 1024
+
+// ErrClaimChecked is returned for an offloaded body by a decoder without a store, such as that of Decode.
+var ErrClaimChecked = errors.New("message body is offloaded - decode it with a Decoder WithClaimCheck, to fetch it from the store")
+
+// ClaimCheckStore stores offloaded message bodies.
+type ClaimCheckStore interface {
+	Store(ctx context.Context, key string, body []byte) (location string, err error)
+	Fetch(ctx context.Context, location string) ([]byte, error)
+}
+
+// claimCheck is the body sent in place of an offloaded body - it carries the content encoding of the stored body, for
+// messages whose attributes were not received.
+type claimCheck struct {
+	ClaimCheck      string
+	Size            int
+	ContentEncoding string `json:",omitempty"`
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// S3ClaimCheckStore stores bodies in a bucket, such as one created by cdkstandards/s3 NewClaimCheckBucket, where they
+// expire by lifecycle rule.
+type S3ClaimCheckStore struct {
+	manager s3manager.S3Manager
+	Bucket  string
+	Prefix  string
+}
+
+func NewS3ClaimCheckStore(manager s3manager.S3Manager, bucket string, prefix string) S3ClaimCheckStore {
+	return S3ClaimCheckStore{manager: manager, Bucket: bucket, Prefix: prefix}
+}
+
+// Store returns the location as s3://bucket/key.
+func (s S3ClaimCheckStore) Store(ctx context.Context, key string, body []byte) (string, error) {
+	key = s.Prefix + key
+
+	err := s.manager.Put(ctx, s.Bucket, key, body)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("s3://%s/%s", s.Bucket, key), nil
+}
+
+func (s S3ClaimCheckStore) Fetch(ctx context.Context, location string) ([]byte, error) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if !ok || !strings.HasPrefix(location, "s3://") {
+		return nil, fmt.Errorf("not an S3 location: %s", location)
+	}
+
+	return s.manager.Get(ctx, bucket, key)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// MemoryClaimCheckStore is for tests.
+type MemoryClaimCheckStore struct {
+	mutex   *sync.Mutex
+	objects map[string][]byte
+}
+
+func NewMemoryClaimCheckStore() MemoryClaimCheckStore {
+	return MemoryClaimCheckStore{mutex: &sync.Mutex{}, objects: map[string][]byte{}}
+}
+
+func (s MemoryClaimCheckStore) Store(ctx context.Context, key string, body []byte) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	location := "memory://" + key
+	s.objects[location] = body
+
+	return location, nil
+}
+
+func (s MemoryClaimCheckStore) Fetch(ctx context.Context, location string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	body, ok := s.objects[location]
+	if !ok {
+		return nil, fmt.Errorf("no such claim check: %s", location)
+	}
+
+	return body, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// checkIn offloads the message body to the store if it is larger than threshold.
+func checkIn(ctx context.Context, store ClaimCheckStore, threshold int, key string, message Message) (Message, error) {
+	if store == nil || message.size() <= threshold {
+		return message, nil
+	}
+
+	location, err := store.Store(ctx, key, []byte(message.Body))
+	if err != nil {
+		return message, err
+	}
+
+	check := claimCheck{ClaimCheck: location, Size: len(message.Body), ContentEncoding: message.Attributes[ContentEncodingAttribute]}
+
+	body, err := json.Marshal(check)
+	if err != nil {
+		return message, err
+	}
+
+	message.Body = string(body)
+	message.Attributes[ClaimCheckAttribute] = location
+
+	return message, nil
+}
+
+// checkOut restores an offloaded message body - the location and content encoding are also read from the body, for
+// messages whose attributes were not received.
+func checkOut(ctx context.Context, store ClaimCheckStore, message Message) (Message, error) {
+	location, ok := message.Attributes[ClaimCheckAttribute]
+	if !ok {
+		var check claimCheck
+
+		err := json.Unmarshal([]byte(message.Body), &check)
+		if err != nil || check.ClaimCheck == "" {
+			return message, nil
+		}
+
+		location = check.ClaimCheck
+
+		if check.ContentEncoding != "" {
+			message.Attributes = maps.Clone(message.Attributes)
+			if message.Attributes == nil {
+				message.Attributes = map[string]string{}
+			}
+
+			message.Attributes[ContentEncodingAttribute] = check.ContentEncoding
+		}
+	}
+
+	if store == nil {
+		return message, fmt.Errorf("%w: %s", ErrClaimChecked, location)
+	}
+
+	body, err := store.Fetch(ctx, location)
+	if err != nil {
+		return message, fmt.Errorf("fetch claim check %s: %w", location, err)
+	}
+
+	message.Body = string(body)
+
+	return message, nil
+}
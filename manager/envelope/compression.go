@@ -0,0 +1,119 @@
+package envelope
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ContentEncodingAttribute is the encoding of a compressed body - its absence signals a plain body.
+const ContentEncodingAttribute = "ContentEncoding"
+
+// CompressionThreshold is the default size below which bodies are sent plain, as compression would gain little.
+const CompressionThreshold = 1024
+
+// Encoding is the value of the content encoding attribute - compressed bodies are base64 encoded, as SNS and SQS
+// only accept text.
+type Encoding string
+
+const (
+	Gzip Encoding = "gzip+base64"
+	Zstd Encoding = "zstd+base64"
+)
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// compress the message body with the encoding if it is larger than threshold - the compressed body is only used if
+// it is smaller.
+func compress(encoding Encoding, threshold int, message Message) (Message, error) {
+	if encoding == "" || len(message.Body) <= threshold {
+		return message, nil
+	}
+
+	var buffer bytes.Buffer
+
+	writer, err := compressor(encoding, &buffer)
+	if err != nil {
+		return message, err
+	}
+
+	_, err = io.WriteString(writer, message.Body)
+	if err != nil {
+		return message, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return message, err
+	}
+
+	body := base64.StdEncoding.EncodeToString(buffer.Bytes())
+	if len(body) >= len(message.Body) {
+		return message, nil
+	}
+
+	message.Body = body
+	message.Attributes[ContentEncodingAttribute] = string(encoding)
+
+	return message, nil
+}
+
+// decompress the message body if it has a content encoding.
+func decompress(message Message) (Message, error) {
+	encoding, ok := message.Attributes[ContentEncodingAttribute]
+	if !ok {
+		return message, nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(message.Body)
+	if err != nil {
+		return message, fmt.Errorf("decode %s body: %w", encoding, err)
+	}
+
+	reader, err := decompressor(Encoding(encoding), bytes.NewReader(compressed))
+	if err != nil {
+		return message, err
+	}
+
+	defer reader.Close()
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return message, fmt.Errorf("decompress %s body: %w", encoding, err)
+	}
+
+	message.Body = string(body)
+
+	return message, nil
+}
+
+func compressor(encoding Encoding, w io.Writer) (io.WriteCloser, error) {
+	switch encoding {
+	case Gzip:
+		return gzip.NewWriter(w), nil
+	case Zstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+func decompressor(encoding Encoding, r io.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case Gzip:
+		return gzip.NewReader(r)
+	case Zstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
@@ -0,0 +1,102 @@
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/snsmanager"
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
+)
+
+// Decoder restores the envelope and its typed payload from a received message - either as published, or wrapped in
+// an SNS notification.
+type Decoder[T any] struct {
+	claimCheck ClaimCheckStore
+}
+
+func NewDecoder[T any]() Decoder[T] {
+	return Decoder[T]{}
+}
+
+// WithClaimCheck returns a decoder that fetches offloaded bodies from the store.
+func (d Decoder[T]) WithClaimCheck(store ClaimCheckStore) Decoder[T] {
+	d.claimCheck = store
+
+	return d
+}
+
+// Decode a plain message body - an offloaded body returns ErrClaimChecked, so use a Decoder WithClaimCheck, and its
+// Decode or DecodeContext, for messages that may have been offloaded.
+func Decode[T any](body string) (Envelope[T], error) {
+	return NewDecoder[T]().Decode(context.Background(), Message{Body: body})
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (d Decoder[T]) Decode(ctx context.Context, message Message) (Envelope[T], error) {
+	return d.decode(ctx, unwrap(message))
+}
+
+// DecodeContext also returns a context carrying the trace context of the message, for processing the event - the
+// envelope's CorrelationID is used if the message has none.
+func (d Decoder[T]) DecodeContext(ctx context.Context, message Message) (context.Context, Envelope[T], error) {
+	message = unwrap(message)
+
+	envelope, err := d.decode(ctx, message)
+	if err != nil {
+		return ctx, envelope, err
+	}
+
+	ctx = tracing.Extract(ctx, message.Attributes)
+
+	trace := tracing.FromContext(ctx)
+	if trace.CorrelationID == "" {
+		trace.CorrelationID = envelope.CorrelationID
+		ctx = tracing.WithTraceContext(ctx, trace)
+	}
+
+	return ctx, envelope, nil
+}
+
+func (d Decoder[T]) decode(ctx context.Context, message Message) (Envelope[T], error) {
+	var envelope Envelope[T]
+
+	message, err := checkOut(ctx, d.claimCheck, message)
+	if err != nil {
+		return envelope, err
+	}
+
+	message, err = decompress(message)
+	if err != nil {
+		return envelope, err
+	}
+
+	err = json.Unmarshal([]byte(message.Body), &envelope)
+	if err != nil {
+		return envelope, fmt.Errorf("decode envelope: %w", err)
+	}
+
+	if envelope.EventType == "" {
+		return envelope, fmt.Errorf("decode envelope: no EventType")
+	}
+
+	return envelope, nil
+}
+
+// unwrap returns the published message and its string attributes if message is an SNS notification.
+func unwrap(message Message) Message {
+	notification, ok := snsmanager.ParseNotification(message.Body)
+	if !ok {
+		return message
+	}
+
+	attributes := notification.StringAttributes()
+
+	// the trace header of the SQS delivery continues the trace
+	if traceHeader, ok := message.Attributes[tracing.AWSTraceHeader]; ok {
+		attributes[tracing.AWSTraceHeader] = traceHeader
+	}
+
+	return Message{Body: notification.Message, Attributes: attributes}
+}
@@ -0,0 +1,49 @@
+package envelope
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// Envelope wraps every published event, so that consumers can identify, trace and de-duplicate events without
+// decoding the payload.
+type Envelope[T any] struct {
+	EventType       string
+	SchemaVersion   int
+	EventID         string
+	OccurredAt      string
+	CorrelationID   string
+	CausationID     string
+	Source          string
+	PolicyOrQuoteID string
+	Payload         T
+}
+
+// UniqueID satisfies the signature of SingleshotHandler.UniqueID, for handlers of enveloped events.
+func (e Envelope[T]) UniqueID() (policyOrQuoteID string, eventID string, err error) {
+	if e.EventID == "" {
+		return "", "", fmt.Errorf("envelope %s has no EventID", e.EventType)
+	}
+
+	return e.PolicyOrQuoteID, e.EventID, nil
+}
+
+// NewEventID returns a random (version 4) UUID.
+func NewEventID() (string, error) {
+	var id [16]byte
+
+	_, err := rand.Read(id[:])
+	if err != nil {
+		return "", fmt.Errorf("NewEventID: %w", err)
+	}
+
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]), nil
+}
+
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,220 @@
+package envelope
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/snsmanager"
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
+	"github.com/bruno-beloff-aviva/event-core/service/testmessage"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+type testTransport struct {
+	messages []Message
+}
+
+func (t *testTransport) Send(ctx context.Context, message Message) (string, error) {
+	t.messages = append(t.messages, message)
+
+	return fmt.Sprintf("message-%d", len(t.messages)), nil
+}
+
+func TestPublishDecode(t *testing.T) {
+	transport := &testTransport{}
+	publisher := NewPublisher[testmessage.TestMessage](transport, "TestMessage", 1, "test-service")
+
+	published, err := publisher.Publish(context.Background(), testmessage.NewTestMessage("client", "/path"), PublishOptions{PolicyOrQuoteID: "P1"})
+	assert.Nil(t, err)
+	assert.Equal(t, published.EventID, published.CorrelationID)
+	assert.Equal(t, "TestMessage", transport.messages[0].Attributes[EventTypeAttribute])
+
+	decoded, err := Decode[testmessage.TestMessage](transport.messages[0].Body)
+	fmt.Println(decoded)
+
+	assert.Nil(t, err)
+	assert.Equal(t, published, decoded)
+
+	policyOrQuoteID, eventID, err := decoded.UniqueID()
+	assert.Nil(t, err)
+	assert.Equal(t, "P1", policyOrQuoteID)
+	assert.Equal(t, published.EventID, eventID)
+}
+
+func TestDecodeSNSNotification(t *testing.T) {
+	transport := &testTransport{}
+	publisher := NewPublisher[testmessage.TestMessage](transport, "TestMessage", 1, "test-service")
+
+	cause := Envelope[string]{EventType: "Cause", EventID: "E1", CorrelationID: "C1", PolicyOrQuoteID: "P1"}
+
+	published, err := PublishFollowing(context.Background(), publisher, cause, testmessage.NewTestMessage("client", "/path"), PublishOptions{})
+	assert.Nil(t, err)
+
+	notification, _ := json.Marshal(snsmanager.Notification{Type: "Notification", MessageId: "M1", TopicArn: "arn:aws:sns:eu-west-2:673007244143:Topic", Message: transport.messages[0].Body})
+
+	decoded, err := Decode[testmessage.TestMessage](string(notification))
+	assert.Nil(t, err)
+	assert.Equal(t, published, decoded)
+	assert.Equal(t, "C1", decoded.CorrelationID)
+	assert.Equal(t, "E1", decoded.CausationID)
+	assert.Equal(t, "P1", decoded.PolicyOrQuoteID)
+}
+
+func TestClaimCheck(t *testing.T) {
+	transport := &testTransport{}
+	store := NewMemoryClaimCheckStore()
+	publisher := NewPublisher[testmessage.TestMessage](transport, "TestMessage", 1, "test-service").WithClaimCheck(store, 500)
+
+	small, err := publisher.Publish(context.Background(), testmessage.NewTestMessage("client", "/"), PublishOptions{})
+	assert.Nil(t, err)
+	assert.NotContains(t, transport.messages[0].Attributes, ClaimCheckAttribute)
+
+	large, err := publisher.Publish(context.Background(), testmessage.NewTestMessage("client", strings.Repeat("/path", 100)), PublishOptions{})
+	assert.Nil(t, err)
+	assert.Contains(t, transport.messages[1].Attributes, ClaimCheckAttribute)
+	fmt.Println(transport.messages[1].Body)
+
+	decoder := NewDecoder[testmessage.TestMessage]().WithClaimCheck(store)
+
+	decoded, err := decoder.Decode(context.Background(), transport.messages[0])
+	assert.Nil(t, err)
+	assert.Equal(t, small, decoded)
+
+	decoded, err = decoder.Decode(context.Background(), transport.messages[1])
+	assert.Nil(t, err)
+	assert.Equal(t, large, decoded)
+
+	_, err = NewDecoder[testmessage.TestMessage]().Decode(context.Background(), transport.messages[1])
+	assert.ErrorIs(t, err, ErrClaimChecked)
+
+	// the body alone identifies the claim check
+	_, err = Decode[testmessage.TestMessage](transport.messages[1].Body)
+	fmt.Println(err)
+	assert.ErrorIs(t, err, ErrClaimChecked)
+
+	decoded, err = decoder.Decode(context.Background(), Message{Body: transport.messages[1].Body})
+	assert.Nil(t, err)
+	assert.Equal(t, large, decoded)
+}
+
+func TestCompression(t *testing.T) {
+	payload := testmessage.NewTestMessage("client", strings.Repeat("/path", 1000))
+
+	for _, encoding := range []Encoding{Gzip, Zstd} {
+		transport := &testTransport{}
+		store := NewMemoryClaimCheckStore()
+		publisher := NewPublisher[testmessage.TestMessage](transport, "TestMessage", 1, "test-service").WithCompression(encoding, 0).WithClaimCheck(store, 0)
+
+		published, err := publisher.Publish(context.Background(), payload, PublishOptions{})
+		assert.Nil(t, err)
+
+		message := transport.messages[0]
+		fmt.Println(encoding, len(message.Body))
+
+		assert.Equal(t, string(encoding), message.Attributes[ContentEncodingAttribute])
+		assert.NotContains(t, message.Attributes, ClaimCheckAttribute)
+		assert.Less(t, len(message.Body), len(payload.Path))
+
+		decoded, err := NewDecoder[testmessage.TestMessage]().Decode(context.Background(), message)
+		assert.Nil(t, err)
+		assert.Equal(t, published, decoded)
+	}
+}
+
+func TestCompressedClaimCheckWithoutAttributes(t *testing.T) {
+	// random bytes, which do not compress below the claim check threshold
+	path := make([]byte, 2000)
+	_, _ = rand.Read(path)
+
+	payload := testmessage.NewTestMessage("client", hex.EncodeToString(path))
+
+	for _, encoding := range []Encoding{Gzip, Zstd} {
+		transport := &testTransport{}
+		store := NewMemoryClaimCheckStore()
+		publisher := NewPublisher[testmessage.TestMessage](transport, "TestMessage", 1, "test-service").WithCompression(encoding, 0).WithClaimCheck(store, 500)
+
+		published, err := publisher.Publish(context.Background(), payload, PublishOptions{})
+		assert.Nil(t, err)
+
+		message := transport.messages[0]
+		fmt.Println(encoding, message.Body)
+
+		assert.Equal(t, string(encoding), message.Attributes[ContentEncodingAttribute])
+		assert.Contains(t, message.Attributes, ClaimCheckAttribute)
+
+		// the body alone gives the content encoding of the stored body
+		decoded, err := NewDecoder[testmessage.TestMessage]().WithClaimCheck(store).Decode(context.Background(), Message{Body: message.Body})
+		assert.Nil(t, err)
+		assert.Equal(t, published, decoded)
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	transport := &testTransport{}
+	publisher := NewPublisher[testmessage.TestMessage](transport, "TestMessage", 1, "test-service")
+
+	traceHeader := "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+	ctx := tracing.WithTraceContext(context.Background(), tracing.TraceContext{TraceHeader: traceHeader, CorrelationID: "C1"})
+
+	published, err := publisher.Publish(ctx, testmessage.NewTestMessage("client", "/path"), PublishOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "C1", published.CorrelationID)
+
+	notification, _ := json.Marshal(snsmanager.Notification{
+		Type:              "Notification",
+		TopicArn:          "arn:aws:sns:eu-west-2:673007244143:Topic",
+		Message:           transport.messages[0].Body,
+		MessageAttributes: map[string]snsmanager.NotificationAttribute{tracing.TraceParentAttribute: {Type: "String", Value: transport.messages[0].Attributes[tracing.TraceParentAttribute]}},
+	})
+
+	ctx, decoded, err := NewDecoder[testmessage.TestMessage]().DecodeContext(context.Background(), Message{Body: string(notification)})
+	assert.Nil(t, err)
+	assert.Equal(t, published, decoded)
+
+	trace := tracing.FromContext(ctx)
+	fmt.Println(trace)
+
+	assert.Equal(t, traceHeader, trace.TraceHeader)
+	assert.Equal(t, "C1", trace.CorrelationID)
+}
+
+func TestPublishWithoutSource(t *testing.T) {
+	transport := &testTransport{}
+	publisher := NewPublisher[testmessage.TestMessage](transport, "TestMessage", 1, "")
+
+	_, err := publisher.Publish(context.Background(), testmessage.NewTestMessage("client", "/path"), PublishOptions{})
+	assert.Nil(t, err)
+
+	_, ok := transport.messages[0].Attributes[SourceAttribute]
+	assert.False(t, ok)
+}
+
+func TestNewEventID(t *testing.T) {
+	eventID, err := NewEventID()
+	fmt.Println(eventID)
+
+	assert.Nil(t, err)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, eventID)
+}
+
+func TestFromSQSMessage(t *testing.T) {
+	message := FromSQSMessage(events.SQSMessage{
+		Body:       "body",
+		Attributes: map[string]string{tracing.AWSTraceHeader: "Root=1-5759e988-bd862e3fe1be46a994272793", "SentTimestamp": "1"},
+		MessageAttributes: map[string]events.SQSMessageAttribute{
+			EventTypeAttribute: {DataType: "String", StringValue: aws.String("TestMessage")},
+			"Binary":           {DataType: "Binary", BinaryValue: []byte{1}},
+		},
+	})
+
+	assert.Equal(t, "body", message.Body)
+	assert.Equal(t, map[string]string{tracing.AWSTraceHeader: "Root=1-5759e988-bd862e3fe1be46a994272793", EventTypeAttribute: "TestMessage"}, message.Attributes)
+}
@@ -0,0 +1,154 @@
+package envelope
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
+)
+
+// message attributes, which subscription filter policies can match without decoding the body
+const (
+	EventTypeAttribute     = "EventType"
+	SchemaVersionAttribute = "SchemaVersion"
+	SourceAttribute        = "Source"
+)
+
+// PublishOptions are all optional - the envelope's EventID is also the deduplication ID if none is given.
+type PublishOptions struct {
+	CorrelationID   string
+	CausationID     string
+	PolicyOrQuoteID string
+	GroupID         string
+	DeduplicationID string
+}
+
+// Publisher publishes payloads of one event type, wrapped in an Envelope.
+type Publisher[T any] struct {
+	transport           Transport
+	EventType           string
+	SchemaVersion       int
+	Source              string
+	encoding            Encoding
+	encodingThreshold   int
+	claimCheck          ClaimCheckStore
+	claimCheckThreshold int
+}
+
+func NewPublisher[T any](transport Transport, eventType string, schemaVersion int, source string) Publisher[T] {
+	return Publisher[T]{transport: transport, EventType: eventType, SchemaVersion: schemaVersion, Source: source}
+}
+
+// WithCompression returns a publisher that compresses bodies larger than threshold bytes - if threshold is zero,
+// CompressionThreshold is used. Bodies are compressed before any claim check.
+func (p Publisher[T]) WithCompression(encoding Encoding, threshold int) Publisher[T] {
+	if threshold <= 0 {
+		threshold = CompressionThreshold
+	}
+
+	p.encoding = encoding
+	p.encodingThreshold = threshold
+
+	return p
+}
+
+// WithClaimCheck returns a publisher that offloads bodies larger than threshold bytes to the store - if threshold is
+// zero, ClaimCheckThreshold is used.
+func (p Publisher[T]) WithClaimCheck(store ClaimCheckStore, threshold int) Publisher[T] {
+	if threshold <= 0 {
+		threshold = ClaimCheckThreshold
+	}
+
+	p.claimCheck = store
+	p.claimCheckThreshold = threshold
+
+	return p
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Publish returns the envelope as published, with the trace context of ctx as message attributes. If no CorrelationID
+// is given, that of ctx is used - if ctx has none, the event starts a new correlation.
+func (p Publisher[T]) Publish(ctx context.Context, payload T, options PublishOptions) (Envelope[T], error) {
+	eventID, err := NewEventID()
+	if err != nil {
+		return Envelope[T]{}, err
+	}
+
+	envelope := Envelope[T]{
+		EventType:       p.EventType,
+		SchemaVersion:   p.SchemaVersion,
+		EventID:         eventID,
+		OccurredAt:      now(),
+		CorrelationID:   options.CorrelationID,
+		CausationID:     options.CausationID,
+		Source:          p.Source,
+		PolicyOrQuoteID: options.PolicyOrQuoteID,
+		Payload:         payload,
+	}
+
+	if envelope.CorrelationID == "" {
+		envelope.CorrelationID = tracing.FromContext(ctx).CorrelationID
+	}
+
+	if envelope.CorrelationID == "" {
+		envelope.CorrelationID = envelope.EventID
+	}
+
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return envelope, err
+	}
+
+	message := Message{
+		Body: string(body),
+		Attributes: map[string]string{
+			EventTypeAttribute:     p.EventType,
+			SchemaVersionAttribute: strconv.Itoa(p.SchemaVersion),
+		},
+		GroupID:         options.GroupID,
+		DeduplicationID: options.DeduplicationID,
+	}
+
+	// an empty string attribute is rejected by SNS and SQS
+	if p.Source != "" {
+		message.Attributes[SourceAttribute] = p.Source
+	}
+
+	for name, value := range tracing.Inject(ctx) {
+		message.Attributes[name] = value
+	}
+
+	message.Attributes[tracing.CorrelationIDAttribute] = envelope.CorrelationID
+
+	if message.GroupID != "" && message.DeduplicationID == "" {
+		message.DeduplicationID = envelope.EventID
+	}
+
+	message, err = compress(p.encoding, p.encodingThreshold, message)
+	if err != nil {
+		return envelope, err
+	}
+
+	message, err = checkIn(ctx, p.claimCheck, p.claimCheckThreshold, p.EventType+"/"+envelope.EventID, message)
+	if err != nil {
+		return envelope, err
+	}
+
+	_, err = p.transport.Send(ctx, message)
+
+	return envelope, err
+}
+
+// PublishFollowing publishes an event caused by cause, in the same correlation.
+func PublishFollowing[T any, C any](ctx context.Context, p Publisher[T], cause Envelope[C], payload T, options PublishOptions) (Envelope[T], error) {
+	options.CorrelationID = cause.CorrelationID
+	options.CausationID = cause.EventID
+
+	if options.PolicyOrQuoteID == "" {
+		options.PolicyOrQuoteID = cause.PolicyOrQuoteID
+	}
+
+	return p.Publish(ctx, payload, options)
+}
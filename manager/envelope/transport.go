@@ -0,0 +1,107 @@
+package envelope
+
+import (
+	"context"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/snsmanager"
+	"github.com/bruno-beloff-aviva/event-core/manager/sqsmanager"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Message is what a Transport sends - GroupID and DeduplicationID are only used by FIFO topics and queues.
+type Message struct {
+	Body            string
+	Attributes      map[string]string
+	GroupID         string
+	DeduplicationID string
+}
+
+// size counts the body and the attributes, as SNS and SQS do.
+func (m Message) size() int {
+	size := len(m.Body)
+
+	for name, value := range m.Attributes {
+		size += len(name) + len(value)
+	}
+
+	return size
+}
+
+// FromSQSMessage is for messages received by a Lambda SQS event source - only string attributes are kept, with the
+// AWSTraceHeader system attribute.
+func FromSQSMessage(message events.SQSMessage) Message {
+	attributes := make(map[string]sqstypes.MessageAttributeValue, len(message.MessageAttributes))
+
+	for name, attribute := range message.MessageAttributes {
+		attributes[name] = sqstypes.MessageAttributeValue{DataType: aws.String(attribute.DataType), StringValue: attribute.StringValue}
+	}
+
+	received := sqstypes.Message{Body: aws.String(message.Body), Attributes: message.Attributes, MessageAttributes: attributes}
+
+	return FromReceivedMessage(received)
+}
+
+// FromReceivedMessage is for messages received by SQSManager, e.g. in a Poller - only string attributes are kept, with
+// the AWSTraceHeader system attribute.
+func FromReceivedMessage(message sqstypes.Message) Message {
+	return Message{Body: aws.ToString(message.Body), Attributes: sqsmanager.StringAttributes(message)}
+}
+
+// Transport sends a message, returning its message ID.
+type Transport interface {
+	Send(ctx context.Context, message Message) (string, error)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type SNSTransport struct {
+	manager  snsmanager.SNSManager
+	TopicArn string
+}
+
+func NewSNSTransport(manager snsmanager.SNSManager, topicArn string) SNSTransport {
+	return SNSTransport{manager: manager, TopicArn: topicArn}
+}
+
+func (t SNSTransport) Send(ctx context.Context, message Message) (string, error) {
+	attributes := make(map[string]types.MessageAttributeValue, len(message.Attributes))
+
+	for name, value := range message.Attributes {
+		attributes[name] = snsmanager.StringAttribute(value)
+	}
+
+	options := snsmanager.PublishOptions{MessageGroupId: message.GroupID, MessageDeduplicationId: message.DeduplicationID, Attributes: attributes}
+
+	result, err := t.manager.Publish(ctx, t.TopicArn, message.Body, options)
+
+	return result.MessageId, err
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+type SQSTransport struct {
+	manager  sqsmanager.SQSManager
+	QueueUrl string
+}
+
+func NewSQSTransport(manager sqsmanager.SQSManager, queueUrl string) SQSTransport {
+	return SQSTransport{manager: manager, QueueUrl: queueUrl}
+}
+
+func (t SQSTransport) Send(ctx context.Context, message Message) (string, error) {
+	attributes := make(map[string]sqstypes.MessageAttributeValue, len(message.Attributes))
+
+	for name, value := range message.Attributes {
+		attributes[name] = sqsmanager.StringAttribute(value)
+	}
+
+	options := sqsmanager.MessageOptions{MessageGroupId: message.GroupID, MessageDeduplicationId: message.DeduplicationID, Attributes: attributes}
+
+	result, err := t.manager.Send(ctx, t.QueueUrl, message.Body, options)
+
+	return result.MessageId, err
+}
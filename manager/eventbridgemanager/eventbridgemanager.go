@@ -0,0 +1,190 @@
+package eventbridgemanager
+
+// https://docs.aws.amazon.com/eventbridge/latest/APIReference/API_PutEvents.html
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/batch"
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/joerdav/zapray"
+	"go.uber.org/zap"
+)
+
+// error codes of entries that may succeed if retried
+var retryableCodes = map[string]bool{
+	"InternalFailure":     true,
+	"ThrottlingException": true,
+}
+
+// Event - Detail must be a JSON object. Time is the time of the event - if zero, the time of the PutEvents call.
+type Event struct {
+	Source     string
+	DetailType string
+	Detail     string
+	Resources  []string
+	Time       time.Time
+}
+
+// PutResult is the result for the event with the same index - Err is nil if the event was put.
+type PutResult struct {
+	EventId string
+	Err     error
+}
+
+// EntryError is a failure reported for a single entry - entries with the retryable codes are retried.
+type EntryError = batch.EntryError
+
+type EventBridgeManager struct {
+	logger            *zapray.Logger
+	eventBridgeClient *eventbridge.Client
+}
+
+func NewEventBridgeManager(logger *zapray.Logger, cfg aws.Config) EventBridgeManager {
+	eventBridgeClient := eventbridge.NewFromConfig(cfg)
+
+	return EventBridgeManager{logger: logger, eventBridgeClient: eventBridgeClient}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (m EventBridgeManager) Pub(ctx context.Context, eventBusName string, source string, detailType string, detail string) error {
+	_, err := m.PutEvent(ctx, eventBusName, Event{Source: source, DetailType: detailType, Detail: detail})
+
+	return err
+}
+
+// PublishEvent marshals detail as the event detail, returning the event ID.
+func PublishEvent[T any](ctx context.Context, m EventBridgeManager, eventBusName string, source string, detailType string, detail T) (string, error) {
+	detailJSON, err := json.Marshal(detail)
+	if err != nil {
+		return "", err
+	}
+
+	return m.PutEvent(ctx, eventBusName, Event{Source: source, DetailType: detailType, Detail: string(detailJSON)})
+}
+
+// PutEvent puts a single event, returning its event ID, or the error for its entry.
+func (m EventBridgeManager) PutEvent(ctx context.Context, eventBusName string, event Event) (string, error) {
+	results, err := m.PutEvents(ctx, eventBusName, []Event{event})
+	if len(results) == 1 && results[0].Err != nil {
+		return "", results[0].Err
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return results[0].EventId, nil
+}
+
+// PutEvents puts the events in batches of at most ten entries and 256 KB, with the X-Ray trace header of ctx,
+// retrying failed entries with backoff. The error is non-nil if any event could not be put.
+func (m EventBridgeManager) PutEvents(ctx context.Context, eventBusName string, events []Event) ([]PutResult, error) {
+	m.logger.Debug("PutEvents", zap.String("eventBusName", eventBusName), zap.Int("events", len(events)))
+
+	traceHeader := tracing.FromContext(ctx).TraceHeader
+
+	results := make([]PutResult, len(events))
+
+	put := func(ctx context.Context, chunk []int) map[int]error {
+		return m.putChunk(ctx, eventBusName, traceHeader, events, chunk, results)
+	}
+
+	// events are not ordered, so are not grouped
+	group := func(event Event) string {
+		return ""
+	}
+
+	errs, err := batch.Retry(ctx, events, entrySize, group, put)
+
+	for i := range results {
+		results[i].Err = errs[i]
+	}
+
+	if err != nil {
+		return results, err
+	}
+
+	if failed := batch.Failed(errs); failed > 0 {
+		m.logger.Error("Couldn't put events", zap.String("eventBusName", eventBusName), zap.Int("failed", failed))
+		return results, fmt.Errorf("%d of %d events could not be put", failed, len(events))
+	}
+
+	return results, nil
+}
+
+// putChunk records the results of the chunk's events that were put, returning the errors of the others.
+func (m EventBridgeManager) putChunk(ctx context.Context, eventBusName string, traceHeader string, events []Event, chunk []int, results []PutResult) map[int]error {
+	entries := make([]types.PutEventsRequestEntry, len(chunk))
+
+	for n, i := range chunk {
+		event := events[i]
+
+		entries[n] = types.PutEventsRequestEntry{
+			EventBusName: aws.String(eventBusName),
+			Source:       aws.String(event.Source),
+			DetailType:   aws.String(event.DetailType),
+			Detail:       aws.String(event.Detail),
+			Resources:    event.Resources,
+		}
+
+		if !event.Time.IsZero() {
+			entries[n].Time = aws.Time(event.Time)
+		}
+
+		if traceHeader != "" {
+			entries[n].TraceHeader = aws.String(traceHeader)
+		}
+	}
+
+	errs := map[int]error{}
+
+	response, err := m.eventBridgeClient.PutEvents(ctx, &eventbridge.PutEventsInput{Entries: entries})
+	if err != nil {
+		m.logger.Error("PutEvents", zap.String("eventBusName", eventBusName), zap.Error(err))
+
+		for _, i := range chunk {
+			errs[i] = err
+		}
+
+		return errs
+	}
+
+	// the result entries are in the order of the request entries
+	for n, entry := range response.Entries {
+		i := chunk[n]
+
+		if entry.ErrorCode == nil {
+			results[i].EventId = aws.ToString(entry.EventId)
+			continue
+		}
+
+		code := aws.ToString(entry.ErrorCode)
+		errs[i] = EntryError{Code: code, Message: aws.ToString(entry.ErrorMessage), Retryable: retryableCodes[code]}
+	}
+
+	return errs
+}
+
+// entrySize is calculated as EventBridge does.
+func entrySize(event Event) int {
+	size := len(event.Source) + len(event.DetailType) + len(event.Detail)
+
+	if !event.Time.IsZero() {
+		size += 14
+	}
+
+	for _, resource := range event.Resources {
+		size += len(resource)
+	}
+
+	return size
+}
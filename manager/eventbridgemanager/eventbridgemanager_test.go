@@ -0,0 +1,179 @@
+package eventbridgemanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/joerdav/zapray"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeEntry struct {
+	EventBusName string
+	Source       string
+	DetailType   string
+	Detail       string
+	TraceHeader  string
+}
+
+type fakeResultEntry struct {
+	EventId      string `json:",omitempty"`
+	ErrorCode    string `json:",omitempty"`
+	ErrorMessage string `json:",omitempty"`
+}
+
+// fakeEventBridge serves PutEvents in the JSON 1.1 protocol, failing each event with its queued error codes, keyed
+// by detail, before putting it.
+type fakeEventBridge struct {
+	mutex    *sync.Mutex
+	failures map[string][]string
+	put      *[]fakeEntry
+	requests *int
+}
+
+func newFakeEventBridge(failures map[string][]string) fakeEventBridge {
+	return fakeEventBridge{mutex: &sync.Mutex{}, failures: failures, put: &[]fakeEntry{}, requests: new(int)}
+}
+
+func newFakeEventBridgeManager(bus fakeEventBridge) EventBridgeManager {
+	cfg := aws.Config{Region: "eu-west-2", Credentials: aws.AnonymousCredentials{}, HTTPClient: bus}
+
+	return NewEventBridgeManager(zapray.NewNop(), cfg)
+}
+
+func (f fakeEventBridge) Do(request *http.Request) (*http.Response, error) {
+	if operation := request.Header.Get("X-Amz-Target"); operation != "AWSEvents.PutEvents" {
+		return nil, fmt.Errorf("fakeEventBridge: unsupported operation: %s", operation)
+	}
+
+	var input struct {
+		Entries []fakeEntry
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	*f.requests++
+
+	output := struct {
+		FailedEntryCount int
+		Entries          []fakeResultEntry
+	}{}
+
+	for _, entry := range input.Entries {
+		if failures := f.failures[entry.Detail]; len(failures) > 0 {
+			f.failures[entry.Detail] = failures[1:]
+			output.FailedEntryCount++
+			output.Entries = append(output.Entries, fakeResultEntry{ErrorCode: failures[0], ErrorMessage: "failed"})
+			continue
+		}
+
+		*f.put = append(*f.put, entry)
+		output.Entries = append(output.Entries, fakeResultEntry{EventId: fmt.Sprintf("event-%d", len(*f.put))})
+	}
+
+	body, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+
+	response := http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.1"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+
+	return &response, nil
+}
+
+func (f fakeEventBridge) Put() []fakeEntry {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]fakeEntry{}, *f.put...)
+}
+
+func (f fakeEventBridge) Requests() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return *f.requests
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func TestPutEvents(t *testing.T) {
+	bus := newFakeEventBridge(map[string][]string{})
+	events := make([]Event, 12)
+
+	for i := range events {
+		events[i] = Event{Source: "test-service", DetailType: "TestMessage", Detail: fmt.Sprintf(`{"N":%d}`, i)}
+	}
+
+	ctx := tracing.WithTraceContext(context.Background(), tracing.TraceContext{TraceHeader: "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1"})
+
+	results, err := newFakeEventBridgeManager(bus).PutEvents(ctx, "bus1", events)
+	fmt.Println(results)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, bus.Requests())
+	assert.Equal(t, 12, len(bus.Put()))
+	assert.Equal(t, "bus1", bus.Put()[0].EventBusName)
+	assert.Equal(t, "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1", bus.Put()[0].TraceHeader)
+	assert.NotEmpty(t, results[11].EventId)
+}
+
+func TestPutEventsRetries(t *testing.T) {
+	bus := newFakeEventBridge(map[string][]string{
+		`{"N":1}`: {"ThrottlingException"},
+		`{"N":2}`: {"MalformedDetail"},
+	})
+
+	events := []Event{
+		{Source: "test-service", DetailType: "TestMessage", Detail: `{"N":0}`},
+		{Source: "test-service", DetailType: "TestMessage", Detail: `{"N":1}`},
+		{Source: "test-service", DetailType: "TestMessage", Detail: `{"N":2}`},
+	}
+
+	results, err := newFakeEventBridgeManager(bus).PutEvents(context.Background(), "bus1", events)
+	fmt.Println(results)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, bus.Requests())
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.NotEmpty(t, results[1].EventId)
+
+	// the entry's own failure is not retried
+	var entryError EntryError
+	assert.True(t, errors.As(results[2].Err, &entryError))
+	assert.Equal(t, "MalformedDetail", entryError.Code)
+	assert.False(t, entryError.Retryable)
+}
+
+func TestPutEvent(t *testing.T) {
+	bus := newFakeEventBridge(map[string][]string{`{"N":2}`: {"MalformedDetail"}})
+	manager := newFakeEventBridgeManager(bus)
+
+	eventId, err := PublishEvent(context.Background(), manager, "bus1", "test-service", "TestMessage", struct{ N int }{1})
+	assert.Nil(t, err)
+	assert.Equal(t, "event-1", eventId)
+
+	_, err = PublishEvent(context.Background(), manager, "bus1", "test-service", "TestMessage", struct{ N int }{2})
+	assert.ErrorContains(t, err, "MalformedDetail")
+}
@@ -0,0 +1,86 @@
+package s3manager
+
+// https://docs.aws.amazon.com/sdk-for-go/v2/developer-guide/go_s3_code_examples.html
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/joerdav/zapray"
+	"go.uber.org/zap"
+)
+
+type S3Manager struct {
+	logger   *zapray.Logger
+	s3Client *s3.Client
+}
+
+func NewS3Manager(logger *zapray.Logger, cfg aws.Config) S3Manager {
+	s3Client := s3.NewFromConfig(cfg)
+
+	return S3Manager{logger: logger, s3Client: s3Client}
+}
+
+// NewS3ManagerWithEndpoint is for S3-compatible stores, such as MinIO, which need path-style addressing.
+func NewS3ManagerWithEndpoint(logger *zapray.Logger, cfg aws.Config, endpoint string) S3Manager {
+	s3Client := s3.NewFromConfig(cfg, func(options *s3.Options) {
+		options.BaseEndpoint = aws.String(endpoint)
+		options.UsePathStyle = true
+	})
+
+	return S3Manager{logger: logger, s3Client: s3Client}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+func (m S3Manager) Put(ctx context.Context, bucket string, key string, body []byte) error {
+	m.logger.Debug("Put: ", zap.String("bucket", bucket), zap.String("key", key), zap.Int("size", len(body)))
+
+	_, err := m.s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	})
+
+	if err != nil {
+		m.logger.Error("Couldn't put object", zap.String("bucket", bucket), zap.String("key", key), zap.Error(err))
+	}
+
+	return err
+}
+
+func (m S3Manager) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	m.logger.Debug("Get: ", zap.String("bucket", bucket), zap.String("key", key))
+
+	response, err := m.s3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		m.logger.Error("Couldn't get object", zap.String("bucket", bucket), zap.String("key", key), zap.Error(err))
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	return io.ReadAll(response.Body)
+}
+
+func (m S3Manager) Delete(ctx context.Context, bucket string, key string) error {
+	m.logger.Debug("Delete: ", zap.String("bucket", bucket), zap.String("key", key))
+
+	_, err := m.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+
+	if err != nil {
+		m.logger.Error("Couldn't delete object", zap.String("bucket", bucket), zap.String("key", key), zap.Error(err))
+	}
+
+	return err
+}
@@ -0,0 +1,46 @@
+package snsmanager
+
+// https://docs.aws.amazon.com/sns/latest/dg/sns-message-and-json-formats.html
+
+import (
+	"encoding/json"
+)
+
+// Notification is the body delivered to an SQS queue by an SNS subscription without raw message delivery.
+type Notification struct {
+	Type              string
+	MessageId         string
+	TopicArn          string
+	Message           string
+	MessageAttributes map[string]NotificationAttribute
+}
+
+type NotificationAttribute struct {
+	Type  string
+	Value string
+}
+
+// ParseNotification returns false if the body is not an SNS notification.
+func ParseNotification(body string) (Notification, bool) {
+	var notification Notification
+
+	err := json.Unmarshal([]byte(body), &notification)
+	if err != nil || notification.Type != "Notification" || notification.TopicArn == "" {
+		return notification, false
+	}
+
+	return notification, true
+}
+
+// StringAttributes returns the string message attributes of the notification.
+func (n Notification) StringAttributes() map[string]string {
+	attributes := make(map[string]string, len(n.MessageAttributes)+1)
+
+	for name, attribute := range n.MessageAttributes {
+		if attribute.Type == "String" {
+			attributes[name] = attribute.Value
+		}
+	}
+
+	return attributes
+}
@@ -0,0 +1,129 @@
+package snsmanager
+
+// https://docs.aws.amazon.com/sns/latest/api/API_PublishBatch.html
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/batch"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"go.uber.org/zap"
+)
+
+type BatchEntry struct {
+	Message string
+	Options PublishOptions
+}
+
+// BatchResult is the result for the entry with the same index - Err is nil if the message was published.
+type BatchResult struct {
+	PublishResult
+	Err error
+}
+
+// BatchEntryError is a failure reported for a single entry - entries that are not the sender's fault are retried.
+type BatchEntryError = batch.EntryError
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// PublishBatch publishes the entries in batches of at most ten entries and 256 KB, retrying throttled and failed
+// entries that are not the sender's fault, with backoff - see batch.Retry for the ordering of FIFO message groups.
+// The error is non-nil if any entry could not be published.
+func (m SNSManager) PublishBatch(ctx context.Context, topicArn string, entries []BatchEntry) ([]BatchResult, error) {
+	m.logger.Debug("PublishBatch", zap.String("topicArn", topicArn), zap.Int("entries", len(entries)))
+
+	results := make([]BatchResult, len(entries))
+
+	publish := func(ctx context.Context, chunk []int) map[int]error {
+		return m.publishChunk(ctx, topicArn, entries, chunk, results)
+	}
+
+	group := func(entry BatchEntry) string {
+		return entry.Options.MessageGroupId
+	}
+
+	errs, err := batch.Retry(ctx, entries, entrySize, group, publish)
+
+	for i := range results {
+		results[i].Err = errs[i]
+	}
+
+	if err != nil {
+		return results, err
+	}
+
+	if failed := batch.Failed(errs); failed > 0 {
+		m.logger.Error("Couldn't publish messages", zap.String("topicArn", topicArn), zap.Int("failed", failed))
+		return results, fmt.Errorf("%d of %d messages could not be published", failed, len(entries))
+	}
+
+	return results, nil
+}
+
+// publishChunk records the results of the chunk's published entries, returning the errors of the others.
+func (m SNSManager) publishChunk(ctx context.Context, topicArn string, entries []BatchEntry, chunk []int, results []BatchResult) map[int]error {
+	requestEntries := make([]types.PublishBatchRequestEntry, len(chunk))
+
+	for n, i := range chunk {
+		options := entries[i].Options
+
+		requestEntries[n] = types.PublishBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			Message:           aws.String(entries[i].Message),
+			MessageAttributes: options.Attributes,
+		}
+
+		if options.Subject != "" {
+			requestEntries[n].Subject = aws.String(options.Subject)
+		}
+
+		if options.MessageGroupId != "" {
+			requestEntries[n].MessageGroupId = aws.String(options.MessageGroupId)
+		}
+
+		if options.MessageDeduplicationId != "" {
+			requestEntries[n].MessageDeduplicationId = aws.String(options.MessageDeduplicationId)
+		}
+	}
+
+	errs := map[int]error{}
+
+	response, err := m.snsClient.PublishBatch(ctx, &sns.PublishBatchInput{TopicArn: aws.String(topicArn), PublishBatchRequestEntries: requestEntries})
+	if err != nil {
+		m.logger.Error("PublishBatch", zap.String("topicArn", topicArn), zap.Error(err))
+
+		for _, i := range chunk {
+			errs[i] = err
+		}
+
+		return errs
+	}
+
+	for _, successful := range response.Successful {
+		i, _ := strconv.Atoi(aws.ToString(successful.Id))
+		results[i].PublishResult = PublishResult{MessageId: aws.ToString(successful.MessageId), SequenceNumber: aws.ToString(successful.SequenceNumber)}
+	}
+
+	for _, failed := range response.Failed {
+		i, _ := strconv.Atoi(aws.ToString(failed.Id))
+		errs[i] = BatchEntryError{Code: aws.ToString(failed.Code), Message: aws.ToString(failed.Message), Retryable: !failed.SenderFault}
+	}
+
+	return errs
+}
+
+// entrySize counts the subject, the body and the attributes.
+func entrySize(entry BatchEntry) int {
+	size := len(entry.Options.Subject) + len(entry.Message)
+
+	for name, attribute := range entry.Options.Attributes {
+		size += len(name) + len(aws.ToString(attribute.DataType)) + len(aws.ToString(attribute.StringValue)) + len(attribute.BinaryValue)
+	}
+
+	return size
+}
@@ -0,0 +1,176 @@
+package snsmanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/batch"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/joerdav/zapray"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeFailure struct {
+	Code        string
+	SenderFault bool
+}
+
+// fakeSNS serves PublishBatch in the SNS query protocol, failing each message with its queued failures before
+// publishing it.
+type fakeSNS struct {
+	mutex     *sync.Mutex
+	failures  map[string][]fakeFailure
+	published *[]string
+	requests  *int
+}
+
+func newFakeSNS(failures map[string][]fakeFailure) fakeSNS {
+	return fakeSNS{mutex: &sync.Mutex{}, failures: failures, published: &[]string{}, requests: new(int)}
+}
+
+func newFakeSNSManager(topic fakeSNS) SNSManager {
+	cfg := aws.Config{Region: "eu-west-2", Credentials: aws.AnonymousCredentials{}, HTTPClient: topic}
+
+	return NewSNSManager(zapray.NewNop(), cfg)
+}
+
+func (f fakeSNS) Do(request *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+
+	if action := form.Get("Action"); action != "PublishBatch" {
+		return nil, fmt.Errorf("fakeSNS: unsupported action: %s", action)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	*f.requests++
+
+	var successful, failed strings.Builder
+
+	for n := 1; form.Has(fmt.Sprintf("PublishBatchRequestEntries.member.%d.Id", n)); n++ {
+		prefix := fmt.Sprintf("PublishBatchRequestEntries.member.%d.", n)
+		id, message := form.Get(prefix+"Id"), form.Get(prefix+"Message")
+
+		if failures := f.failures[message]; len(failures) > 0 {
+			f.failures[message] = failures[1:]
+			fmt.Fprintf(&failed, "<member><Id>%s</Id><Code>%s</Code><Message>failed</Message><SenderFault>%t</SenderFault></member>", id, failures[0].Code, failures[0].SenderFault)
+			continue
+		}
+
+		*f.published = append(*f.published, message)
+		fmt.Fprintf(&successful, "<member><Id>%s</Id><MessageId>%s-id</MessageId></member>", id, message)
+	}
+
+	output := fmt.Sprintf(`<PublishBatchResponse xmlns="http://sns.amazonaws.com/doc/2010-03-31/"><PublishBatchResult><Successful>%s</Successful><Failed>%s</Failed></PublishBatchResult><ResponseMetadata><RequestId>request1</RequestId></ResponseMetadata></PublishBatchResponse>`, successful.String(), failed.String())
+
+	response := http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"text/xml"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(output))),
+	}
+
+	return &response, nil
+}
+
+func (f fakeSNS) Published() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, *f.published...)
+}
+
+func (f fakeSNS) Requests() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return *f.requests
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+const testTopicArn = "arn:aws:sns:eu-west-2:123456789012:Topic"
+
+func TestPublishBatch(t *testing.T) {
+	topic := newFakeSNS(map[string][]fakeFailure{})
+	entries := make([]BatchEntry, 12)
+
+	for i := range entries {
+		entries[i] = BatchEntry{Message: fmt.Sprintf("m%d", i)}
+	}
+
+	results, err := newFakeSNSManager(topic).PublishBatch(context.Background(), testTopicArn, entries)
+	fmt.Println(results)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, topic.Requests())
+	assert.Equal(t, 12, len(topic.Published()))
+	assert.Equal(t, "m11-id", results[11].MessageId)
+}
+
+func TestPublishBatchRetries(t *testing.T) {
+	topic := newFakeSNS(map[string][]fakeFailure{
+		"retried":  {{Code: "InternalError", SenderFault: false}},
+		"rejected": {{Code: "InvalidParameter", SenderFault: true}},
+	})
+
+	entries := []BatchEntry{{Message: "ok"}, {Message: "retried"}, {Message: "rejected"}}
+
+	results, err := newFakeSNSManager(topic).PublishBatch(context.Background(), testTopicArn, entries)
+	fmt.Println(results)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"ok", "retried"}, topic.Published())
+
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.Equal(t, "retried-id", results[1].MessageId)
+
+	// the sender's fault is not retried
+	var entryError BatchEntryError
+	assert.True(t, errors.As(results[2].Err, &entryError))
+	assert.Equal(t, "InvalidParameter", entryError.Code)
+	assert.False(t, entryError.Retryable)
+}
+
+func TestPublishBatchGroupFailed(t *testing.T) {
+	topic := newFakeSNS(map[string][]fakeFailure{
+		"g1-first": {{Code: "InvalidParameter", SenderFault: true}},
+	})
+
+	entries := []BatchEntry{
+		{Message: "g1-first", Options: PublishOptions{MessageGroupId: "g1"}},
+		{Message: "g2-first", Options: PublishOptions{MessageGroupId: "g2"}},
+	}
+
+	for i := 0; i < 10; i++ {
+		entries = append(entries, BatchEntry{Message: fmt.Sprintf("g1-later%d", i), Options: PublishOptions{MessageGroupId: "g1"}})
+	}
+
+	results, err := newFakeSNSManager(topic).PublishBatch(context.Background(), testTopicArn, entries)
+	fmt.Println(results)
+
+	assert.NotNil(t, err)
+
+	// the later entries of the group in the second request are not published out of order
+	assert.Nil(t, results[1].Err)
+	assert.ErrorIs(t, results[11].Err, batch.ErrGroupFailed)
+	assert.NotContains(t, topic.Published(), "g1-later9")
+}
@@ -4,13 +4,30 @@ package snsmanager
 
 import (
 	"context"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
 	"github.com/joerdav/zapray"
 	"go.uber.org/zap"
 )
 
+// PublishOptions are all optional - FIFO topics require a MessageGroupId, and a MessageDeduplicationId unless the
+// topic has content-based deduplication. Attributes are matched by subscription filter policies.
+type PublishOptions struct {
+	Subject                string
+	MessageGroupId         string
+	MessageDeduplicationId string
+	Attributes             map[string]types.MessageAttributeValue
+}
+
+// SequenceNumber is only given by FIFO topics.
+type PublishResult struct {
+	MessageId      string
+	SequenceNumber string
+}
+
 type SNSManager struct {
 	logger    *zapray.Logger
 	snsClient *sns.Client
@@ -22,15 +39,57 @@ func NewSNSManager(logger *zapray.Logger, cfg aws.Config) SNSManager {
 	return SNSManager{logger: logger, snsClient: snsClient}
 }
 
+func StringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func NumberAttribute(value float64) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("Number"), StringValue: aws.String(strconv.FormatFloat(value, 'f', -1, 64))}
+}
+
+// StringArrayAttribute takes the JSON array, e.g. ["a", "b"] - filter policies match any of its elements.
+func StringArrayAttribute(jsonArray string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("String.Array"), StringValue: aws.String(jsonArray)}
+}
+
+func BinaryAttribute(value []byte) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("Binary"), BinaryValue: value}
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 func (m SNSManager) Pub(ctx context.Context, topicArn string, message string) error {
-	m.logger.Debug("Pub", zap.String("topicArn", topicArn))
+	_, err := m.Publish(ctx, topicArn, message, PublishOptions{})
 
-	publishInput := sns.PublishInput{TopicArn: aws.String(topicArn), Message: aws.String(message)}
+	return err
+}
+
+func (m SNSManager) Publish(ctx context.Context, topicArn string, message string, options PublishOptions) (PublishResult, error) {
+	m.logger.Debug("Publish", zap.String("topicArn", topicArn), zap.String("messageGroupId", options.MessageGroupId))
+
+	publishInput := sns.PublishInput{
+		TopicArn:          aws.String(topicArn),
+		Message:           aws.String(message),
+		MessageAttributes: options.Attributes,
+	}
+
+	if options.Subject != "" {
+		publishInput.Subject = aws.String(options.Subject)
+	}
+
+	if options.MessageGroupId != "" {
+		publishInput.MessageGroupId = aws.String(options.MessageGroupId)
+	}
 
-	_, err := m.snsClient.Publish(ctx, &publishInput)
+	if options.MessageDeduplicationId != "" {
+		publishInput.MessageDeduplicationId = aws.String(options.MessageDeduplicationId)
+	}
+
+	response, err := m.snsClient.Publish(ctx, &publishInput)
 	if err != nil {
 		m.logger.Error("Couldn't publish message", zap.String("topicArn", topicArn), zap.Error(err))
+		return PublishResult{}, err
 	}
 
-	return err
+	return PublishResult{MessageId: aws.ToString(response.MessageId), SequenceNumber: aws.ToString(response.SequenceNumber)}, nil
 }
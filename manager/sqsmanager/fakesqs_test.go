@@ -0,0 +1,245 @@
+package sqsmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/joerdav/zapray"
+)
+
+// fakeMessage is a message as it is exchanged in the SQS JSON protocol.
+type fakeMessage struct {
+	MessageId         string
+	ReceiptHandle     string
+	Body              string
+	Attributes        map[string]string               `json:",omitempty"`
+	MessageAttributes map[string]fakeMessageAttribute `json:",omitempty"`
+}
+
+type fakeMessageAttribute struct {
+	DataType    string
+	StringValue string
+}
+
+type fakeVisibilityChange struct {
+	ReceiptHandle     string
+	VisibilityTimeout int32
+	At                time.Time
+}
+
+type fakeSent struct {
+	QueueUrl    string
+	MessageBody string
+}
+
+// fakeSQS serves ReceiveMessage, DeleteMessage, ChangeMessageVisibility(Batch), SendMessage and GetQueueAttributes for
+// a single queue, whose messages are received once each, so that SQSManager can be tested end to end. With redelivery,
+// the messages received and not deleted become visible again before the next receive, as if their visibility timeout
+// had expired, or at once if their visibility timeout is changed to zero.
+type fakeSQS struct {
+	mutex             *sync.Mutex
+	redeliver         bool
+	visible           *[]fakeMessage
+	inFlight          *[]fakeMessage
+	deleted           *[]string
+	visibilityChanges *[]fakeVisibilityChange
+	sent              *[]fakeSent
+}
+
+func newFakeSQS(messages ...fakeMessage) fakeSQS {
+	for i := range messages {
+		if messages[i].ReceiptHandle == "" {
+			messages[i].ReceiptHandle = messages[i].MessageId + "-handle"
+		}
+	}
+
+	return fakeSQS{
+		mutex:             &sync.Mutex{},
+		visible:           &messages,
+		inFlight:          &[]fakeMessage{},
+		deleted:           &[]string{},
+		visibilityChanges: &[]fakeVisibilityChange{},
+		sent:              &[]fakeSent{},
+	}
+}
+
+func (f fakeSQS) WithRedelivery() fakeSQS {
+	f.redeliver = true
+
+	return f
+}
+
+func newFakeSQSManager(queue fakeSQS) SQSManager {
+	cfg := aws.Config{Region: "eu-west-2", Credentials: aws.AnonymousCredentials{}, HTTPClient: queue}
+
+	return NewSQSManager(zapray.NewNop(), cfg)
+}
+
+func (f fakeSQS) Do(request *http.Request) (*http.Response, error) {
+	var input struct {
+		QueueUrl            string
+		MaxNumberOfMessages int
+		ReceiptHandle       string
+		VisibilityTimeout   int32
+		MessageBody         string
+		Entries             []struct {
+			Id                string
+			ReceiptHandle     string
+			VisibilityTimeout int32
+		}
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	operation := request.Header.Get("X-Amz-Target")
+	output := map[string]any{}
+
+	switch {
+	case strings.HasSuffix(operation, ".ReceiveMessage"):
+		messages := f.receive(max(input.MaxNumberOfMessages, 1))
+
+		// as a long poll that times out, but briefly
+		if len(messages) == 0 {
+			select {
+			case <-request.Context().Done():
+				return nil, request.Context().Err()
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+
+		output["Messages"] = messages
+
+	case strings.HasSuffix(operation, ".DeleteMessage"):
+		f.mutex.Lock()
+		*f.deleted = append(*f.deleted, input.ReceiptHandle)
+		f.takeInFlight(input.ReceiptHandle)
+		f.mutex.Unlock()
+
+	case strings.HasSuffix(operation, ".ChangeMessageVisibility"):
+		f.changeVisibility(input.ReceiptHandle, input.VisibilityTimeout)
+
+	case strings.HasSuffix(operation, ".ChangeMessageVisibilityBatch"):
+		var successful []map[string]string
+
+		for _, entry := range input.Entries {
+			f.changeVisibility(entry.ReceiptHandle, entry.VisibilityTimeout)
+			successful = append(successful, map[string]string{"Id": entry.Id})
+		}
+
+		output["Successful"] = successful
+
+	case strings.HasSuffix(operation, ".SendMessage"):
+		f.mutex.Lock()
+		*f.sent = append(*f.sent, fakeSent{QueueUrl: input.QueueUrl, MessageBody: input.MessageBody})
+		output["MessageId"] = fmt.Sprintf("sent-%d", len(*f.sent))
+		f.mutex.Unlock()
+
+	case strings.HasSuffix(operation, ".GetQueueAttributes"):
+		f.mutex.Lock()
+		output["Attributes"] = map[string]string{"ApproximateNumberOfMessages": strconv.Itoa(len(*f.visible))}
+		f.mutex.Unlock()
+
+	default:
+		return nil, fmt.Errorf("fakeSQS: unsupported operation: %s", operation)
+	}
+
+	body, err := json.Marshal(output)
+	if err != nil {
+		return nil, err
+	}
+
+	response := http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/x-amz-json-1.0"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+
+	return &response, nil
+}
+
+func (f fakeSQS) receive(maxMessages int) []fakeMessage {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.redeliver {
+		*f.visible = append(*f.visible, *f.inFlight...)
+		*f.inFlight = []fakeMessage{}
+	}
+
+	count := min(maxMessages, len(*f.visible))
+	messages := append([]fakeMessage{}, (*f.visible)[:count]...)
+	*f.visible = (*f.visible)[count:]
+	*f.inFlight = append(*f.inFlight, messages...)
+
+	return messages
+}
+
+func (f fakeSQS) changeVisibility(receiptHandle string, visibilityTimeout int32) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	*f.visibilityChanges = append(*f.visibilityChanges, fakeVisibilityChange{ReceiptHandle: receiptHandle, VisibilityTimeout: visibilityTimeout, At: time.Now()})
+
+	if f.redeliver && visibilityTimeout == 0 {
+		if message, ok := f.takeInFlight(receiptHandle); ok {
+			*f.visible = append(*f.visible, message)
+		}
+	}
+}
+
+// takeInFlight must be called with the mutex locked.
+func (f fakeSQS) takeInFlight(receiptHandle string) (fakeMessage, bool) {
+	for i, message := range *f.inFlight {
+		if message.ReceiptHandle == receiptHandle {
+			*f.inFlight = append((*f.inFlight)[:i], (*f.inFlight)[i+1:]...)
+			return message, true
+		}
+	}
+
+	return fakeMessage{}, false
+}
+
+func (f fakeSQS) Deleted() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]string{}, *f.deleted...)
+}
+
+func (f fakeSQS) Sent() []fakeSent {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]fakeSent{}, *f.sent...)
+}
+
+func (f fakeSQS) VisibilityChanges() []fakeVisibilityChange {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]fakeVisibilityChange{}, *f.visibilityChanges...)
+}
+
+// Released returns the receipt handles of the messages made visible again.
+func (f fakeSQS) Released() []string {
+	var released []string
+
+	for _, change := range f.VisibilityChanges() {
+		if change.VisibilityTimeout == 0 {
+			released = append(released, change.ReceiptHandle)
+		}
+	}
+
+	return released
+}
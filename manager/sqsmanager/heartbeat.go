@@ -0,0 +1,135 @@
+package sqsmanager
+
+// https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/sqs-visibility-timeout.html
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"go.uber.org/zap"
+)
+
+// VisibilityHeartbeat keeps an in-flight message invisible while it is being processed, by extending its visibility
+// timeout at every interval.
+type VisibilityHeartbeat struct {
+	manager           SQSManager
+	QueueUrl          string
+	ReceiptHandle     string
+	VisibilityTimeout time.Duration
+	Interval          time.Duration
+}
+
+// the DNS suffixes of the SQS endpoints in each partition
+var partitionDNSSuffixes = map[string]string{
+	"aws":        "amazonaws.com",
+	"aws-cn":     "amazonaws.com.cn",
+	"aws-us-gov": "amazonaws.com",
+	"aws-iso":    "c2s.ic.gov",
+	"aws-iso-b":  "sc2s.sgov.gov",
+}
+
+// NewVisibilityHeartbeat extends the visibility at half the timeout. SQS takes whole seconds, so the timeout is
+// truncated to seconds, with a minimum of one second.
+func NewVisibilityHeartbeat(manager SQSManager, queueUrl string, receiptHandle string, visibilityTimeout time.Duration) VisibilityHeartbeat {
+	visibilityTimeout = max(visibilityTimeout.Truncate(time.Second), time.Second)
+
+	return VisibilityHeartbeat{
+		manager:           manager,
+		QueueUrl:          queueUrl,
+		ReceiptHandle:     receiptHandle,
+		VisibilityTimeout: visibilityTimeout,
+		Interval:          visibilityTimeout / 2,
+	}
+}
+
+// NewMessageHeartbeat is for a message received by a Lambda SQS event source.
+func NewMessageHeartbeat(manager SQSManager, message events.SQSMessage, visibilityTimeout time.Duration) (VisibilityHeartbeat, error) {
+	queueUrl, err := QueueUrlFromArn(message.EventSourceARN)
+	if err != nil {
+		return VisibilityHeartbeat{}, err
+	}
+
+	return NewVisibilityHeartbeat(manager, queueUrl, message.ReceiptHandle, visibilityTimeout), nil
+}
+
+// QueueUrlFromArn converts arn:partition:sqs:region:account:name to the queue URL, in the DNS domain of the partition.
+func QueueUrlFromArn(arn string) (string, error) {
+	fields := strings.Split(arn, ":")
+
+	if len(fields) != 6 || fields[0] != "arn" || fields[2] != "sqs" {
+		return "", fmt.Errorf("not an SQS queue ARN: %s", arn)
+	}
+
+	dnsSuffix, ok := partitionDNSSuffixes[fields[1]]
+	if !ok {
+		return "", fmt.Errorf("unknown partition in SQS queue ARN: %s", arn)
+	}
+
+	return fmt.Sprintf("https://sqs.%s.%s/%s/%s", fields[3], dnsSuffix, fields[4], fields[5]), nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// WithHeartbeat runs process with the heartbeat, stopping the heartbeat when process returns.
+func WithHeartbeat(ctx context.Context, heartbeat VisibilityHeartbeat, process func(ctx context.Context) error) error {
+	stop := heartbeat.Start(ctx)
+	defer stop()
+
+	return process(ctx)
+}
+
+// Start the heartbeat - it stops when stop is called, when ctx is done, or when the ctx deadline is too close for
+// another extension to be useful, as at the end of a Lambda invocation. stop returns when the heartbeat has stopped,
+// so that the message can then be deleted.
+func (h VisibilityHeartbeat) Start(ctx context.Context) (stop func()) {
+	if h.Interval <= 0 || h.VisibilityTimeout < time.Second {
+		panic(fmt.Sprintf("VisibilityHeartbeat: invalid interval %s or visibility timeout %s", h.Interval, h.VisibilityTimeout))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	ticker := time.NewTicker(h.Interval)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case <-ticker.C:
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < h.Interval {
+					h.manager.logger.Debug("VisibilityHeartbeat: deadline approaching", zap.Time("deadline", deadline))
+					return
+				}
+
+				err := h.extend(ctx)
+				if err != nil {
+					h.manager.logger.Error("ChangeMessageVisibility", zap.String("queueUrl", h.QueueUrl), zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func (h VisibilityHeartbeat) extend(ctx context.Context) error {
+	_, err := h.manager.sqsClient.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          aws.String(h.QueueUrl),
+		ReceiptHandle:     aws.String(h.ReceiptHandle),
+		VisibilityTimeout: int32(h.VisibilityTimeout / time.Second),
+	})
+
+	return err
+}
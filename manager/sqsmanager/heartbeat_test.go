@@ -0,0 +1,43 @@
+package sqsmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueUrlFromArn(t *testing.T) {
+	queueUrl, err := QueueUrlFromArn("arn:aws:sqs:eu-west-2:673007244143:SQS1Queue")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://sqs.eu-west-2.amazonaws.com/673007244143/SQS1Queue", queueUrl)
+
+	queueUrl, err = QueueUrlFromArn("arn:aws-cn:sqs:cn-north-1:673007244143:SQS1Queue")
+	assert.Nil(t, err)
+	assert.Equal(t, "https://sqs.cn-north-1.amazonaws.com.cn/673007244143/SQS1Queue", queueUrl)
+
+	_, err = QueueUrlFromArn("arn:aws:sns:eu-west-2:673007244143:SQS1Topic")
+	assert.NotNil(t, err)
+
+	_, err = QueueUrlFromArn("arn:aws-unknown:sqs:eu-west-2:673007244143:SQS1Queue")
+	assert.NotNil(t, err)
+}
+
+func TestNewVisibilityHeartbeat(t *testing.T) {
+	heartbeat := NewVisibilityHeartbeat(SQSManager{}, "queueUrl", "handle", 500*time.Millisecond)
+	assert.Equal(t, time.Second, heartbeat.VisibilityTimeout)
+	assert.Equal(t, 500*time.Millisecond, heartbeat.Interval)
+
+	heartbeat = NewVisibilityHeartbeat(SQSManager{}, "queueUrl", "handle", 0)
+	assert.Equal(t, time.Second, heartbeat.VisibilityTimeout)
+
+	heartbeat = NewVisibilityHeartbeat(SQSManager{}, "queueUrl", "handle", 2500*time.Millisecond)
+	assert.Equal(t, 2*time.Second, heartbeat.VisibilityTimeout)
+	assert.Equal(t, time.Second, heartbeat.Interval)
+
+	stop := heartbeat.Start(context.Background())
+	stop()
+
+	assert.Panics(t, func() { VisibilityHeartbeat{}.Start(context.Background()) })
+}
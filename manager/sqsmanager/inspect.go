@@ -0,0 +1,274 @@
+package sqsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/snsmanager"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+// origins of inspected messages
+const (
+	OriginSQS         = "sqs"
+	OriginSNS         = "sns"
+	OriginEventBridge = "eventbridge"
+	OriginLambda      = "lambda"
+)
+
+// group keys for Inspect
+const (
+	GroupByError     = "error"
+	GroupByEventType = "event-type"
+)
+
+// InspectVisibilityTimeout is the default visibility timeout of inspected messages, in seconds.
+const InspectVisibilityTimeout = 300
+
+const (
+	// a conservative rate of receiving messages, ten at a time, for estimating the duration of a scan
+	inspectMessagesPerSecond = 20
+
+	maxVisibilityTimeout = 12 * 60 * 60
+)
+
+// InspectOptions - MaxMessages is unlimited if zero. Messages are not deleted, and are hidden for VisibilityTimeout
+// seconds - this must be longer than the scan, or the scan stops when the first message is received again. They are
+// made visible again at the end of the scan. If zero, InspectVisibilityTimeout is used, or longer if MaxMessages is
+// large.
+type InspectOptions struct {
+	QueueUrl          string
+	MaxMessages       int
+	VisibilityTimeout int32
+	GroupBy           string
+	Dump              io.Writer
+}
+
+// InspectedMessage is a line of the JSONL dump - Body is the innermost body, after any SNS, EventBridge or Lambda
+// destination wrapper has been removed.
+type InspectedMessage struct {
+	MessageId      string
+	Origin         string
+	EventType      string `json:",omitempty"`
+	ErrorSignature string `json:",omitempty"`
+	ErrorMessage   string `json:",omitempty"`
+	MessageGroupId string `json:",omitempty"`
+	ReceiveCount   string `json:",omitempty"`
+	SentTimestamp  string `json:",omitempty"`
+	Attributes     map[string]string
+	Body           string
+}
+
+// InspectGroup counts the messages with the same error signature or event type.
+type InspectGroup struct {
+	Key        string
+	Count      int
+	MessageIds []string
+}
+
+type InspectReport struct {
+	Total  int
+	Groups []InspectGroup
+}
+
+// wrappers of the inspected body
+
+type eventBridgeEvent struct {
+	DetailType string          `json:"detail-type"`
+	Source     string          `json:"source"`
+	Detail     json.RawMessage `json:"detail"`
+}
+
+type lambdaDestination struct {
+	RequestContext struct {
+		Condition string `json:"condition"`
+	} `json:"requestContext"`
+	RequestPayload  json.RawMessage `json:"requestPayload"`
+	ResponsePayload struct {
+		ErrorType    string `json:"errorType"`
+		ErrorMessage string `json:"errorMessage"`
+	} `json:"responsePayload"`
+}
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	arnPattern    = regexp.MustCompile(`arn:aws[\w-]*:[\w-]+:[\w-]*:\d*:[^\s"]+`)
+	numberPattern = regexp.MustCompile(`\d+`)
+)
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Inspect peeks at the messages in a queue - typically a DLQ - without deleting them, dumping each message and
+// grouping them by error signature or event type. Groups are in descending order of count.
+//
+// Every inspection receives the messages, incrementing their ApproximateReceiveCount - if the queue has a redrive
+// policy of its own, repeated inspections move its messages on to that DLQ. The inspected messages are made visible
+// again when the scan ends, whether or not it fails.
+func (m SQSManager) Inspect(ctx context.Context, options InspectOptions) (InspectReport, error) {
+	m.logger.Info("Inspect", zap.String("queueUrl", options.QueueUrl), zap.String("groupBy", options.GroupBy))
+
+	var report InspectReport
+
+	var dump *json.Encoder
+	if options.Dump != nil {
+		dump = json.NewEncoder(options.Dump)
+	}
+
+	groups := map[string]*InspectGroup{}
+
+	var received []types.Message
+
+	_, err := m.receiveAll(ctx, options.QueueUrl, inspectVisibilityTimeout(options), func(message types.Message) (bool, error) {
+		if options.MaxMessages > 0 && report.Total >= options.MaxMessages {
+			return false, nil
+		}
+
+		report.Total++
+		received = append(received, message)
+
+		inspected := InspectMessage(message)
+
+		key := inspected.EventType
+		if options.GroupBy == GroupByError {
+			key = inspected.ErrorSignature
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = &InspectGroup{Key: key}
+			groups[key] = group
+		}
+
+		group.Count++
+		group.MessageIds = append(group.MessageIds, inspected.MessageId)
+
+		if dump != nil {
+			return true, dump.Encode(inspected)
+		}
+
+		return true, nil
+	})
+
+	m.release(ctx, options.QueueUrl, received)
+
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+	}
+
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Count != report.Groups[j].Count {
+			return report.Groups[i].Count > report.Groups[j].Count
+		}
+
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+
+	return report, err
+}
+
+func inspectVisibilityTimeout(options InspectOptions) int32 {
+	if options.VisibilityTimeout > 0 {
+		return options.VisibilityTimeout
+	}
+
+	return int32(min(max(InspectVisibilityTimeout, options.MaxMessages/inspectMessagesPerSecond), maxVisibilityTimeout))
+}
+
+// InspectMessage decodes any SNS notification, EventBridge event or Lambda destination record in the message body.
+// The event type is taken from an EventType attribute or field, or the EventBridge detail-type. The error is taken from
+// the attributes set by EventBridge for its target DLQs, or from the Lambda destination record.
+func InspectMessage(message types.Message) InspectedMessage {
+	inspected := InspectedMessage{
+		MessageId:      aws.ToString(message.MessageId),
+		Origin:         OriginSQS,
+		MessageGroupId: message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)],
+		ReceiveCount:   message.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)],
+		SentTimestamp:  message.Attributes[string(types.MessageSystemAttributeNameSentTimestamp)],
+		Attributes:     map[string]string{},
+		Body:           aws.ToString(message.Body),
+	}
+
+	for name, attribute := range message.MessageAttributes {
+		if attribute.StringValue != nil {
+			inspected.Attributes[name] = *attribute.StringValue
+		}
+	}
+
+	inspected.ErrorMessage = strings.TrimSpace(inspected.Attributes["ERROR_CODE"] + " " + inspected.Attributes["ERROR_MESSAGE"])
+
+	inspected.unwrap()
+
+	if inspected.EventType == "" {
+		inspected.EventType = inspected.Attributes["EventType"]
+	}
+
+	if inspected.EventType == "" {
+		var fields struct{ EventType string }
+
+		_ = json.Unmarshal([]byte(inspected.Body), &fields)
+		inspected.EventType = fields.EventType
+	}
+
+	if inspected.EventType == "" {
+		inspected.EventType = "unknown"
+	}
+
+	inspected.ErrorSignature = ErrorSignature(inspected.ErrorMessage)
+
+	return inspected
+}
+
+// unwrap removes the wrappers, innermost last.
+func (i *InspectedMessage) unwrap() {
+	var destination lambdaDestination
+
+	if json.Unmarshal([]byte(i.Body), &destination) == nil && destination.RequestContext.Condition != "" {
+		i.Origin = OriginLambda
+		i.Body = string(destination.RequestPayload)
+
+		if i.ErrorMessage == "" {
+			i.ErrorMessage = strings.TrimSpace(destination.ResponsePayload.ErrorType + " " + destination.ResponsePayload.ErrorMessage)
+		}
+
+		if i.ErrorMessage == "" {
+			i.ErrorMessage = destination.RequestContext.Condition
+		}
+	}
+
+	if notification, ok := snsmanager.ParseNotification(i.Body); ok {
+		i.Origin = OriginSNS
+		i.Body = notification.Message
+
+		maps.Copy(i.Attributes, notification.StringAttributes())
+	}
+
+	var event eventBridgeEvent
+
+	if json.Unmarshal([]byte(i.Body), &event) == nil && event.DetailType != "" && event.Detail != nil {
+		i.Origin = OriginEventBridge
+		i.EventType = event.DetailType
+		i.Body = string(event.Detail)
+	}
+}
+
+// ErrorSignature normalises an error message by replacing the UUIDs, ARNs and numbers in it, so that messages that
+// failed for the same reason have the same signature.
+func ErrorSignature(errorMessage string) string {
+	if errorMessage == "" {
+		return "none"
+	}
+
+	signature := uuidPattern.ReplaceAllString(errorMessage, "<uuid>")
+	signature = arnPattern.ReplaceAllString(signature, "<arn>")
+	signature = numberPattern.ReplaceAllString(signature, "<n>")
+
+	return signature
+}
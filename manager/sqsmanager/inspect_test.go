@@ -0,0 +1,113 @@
+package sqsmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspectSNSMessage(t *testing.T) {
+	message := types.Message{
+		MessageId: aws.String("M1"),
+		Body:      aws.String(`{"Type":"Notification","TopicArn":"arn:aws:sns:eu-west-2:673007244143:Topic","Message":"{\"EventType\":\"TestMessage\"}","MessageAttributes":{"Source":{"Type":"String","Value":"test-service"}}}`),
+	}
+
+	inspected := InspectMessage(message)
+	fmt.Println(inspected)
+
+	assert.Equal(t, OriginSNS, inspected.Origin)
+	assert.Equal(t, "TestMessage", inspected.EventType)
+	assert.Equal(t, "test-service", inspected.Attributes["Source"])
+	assert.Equal(t, `{"EventType":"TestMessage"}`, inspected.Body)
+	assert.Equal(t, "none", inspected.ErrorSignature)
+}
+
+func TestInspectEventBridgeMessage(t *testing.T) {
+	message := types.Message{
+		MessageId: aws.String("M2"),
+		Body:      aws.String(`{"detail-type":"PolicyIssued","source":"policy-service","detail":{"PolicyID":"P1"}}`),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"ERROR_CODE":    StringAttribute("SQS_PERMISSION_DENIED"),
+			"ERROR_MESSAGE": StringAttribute("Access denied to arn:aws:sqs:eu-west-2:673007244143:Queue1 after 185 attempts"),
+		},
+	}
+
+	inspected := InspectMessage(message)
+
+	assert.Equal(t, OriginEventBridge, inspected.Origin)
+	assert.Equal(t, "PolicyIssued", inspected.EventType)
+	assert.Equal(t, `{"PolicyID":"P1"}`, inspected.Body)
+	assert.Equal(t, "SQS_PERMISSION_DENIED Access denied to <arn> after <n> attempts", inspected.ErrorSignature)
+}
+
+func TestInspectLambdaDestinationMessage(t *testing.T) {
+	message := types.Message{
+		MessageId: aws.String("M3"),
+		Body:      aws.String(`{"requestContext":{"condition":"RetriesExhausted"},"requestPayload":{"EventType":"TestMessage"},"responsePayload":{"errorType":"errorString","errorMessage":"event 5a6d723f-8103-4b1a-8179-821b021f7c06 failed"}}`),
+	}
+
+	inspected := InspectMessage(message)
+
+	assert.Equal(t, OriginLambda, inspected.Origin)
+	assert.Equal(t, "TestMessage", inspected.EventType)
+	assert.Equal(t, "errorString event <uuid> failed", inspected.ErrorSignature)
+}
+
+func TestInspectVisibilityTimeout(t *testing.T) {
+	assert.Equal(t, int32(InspectVisibilityTimeout), inspectVisibilityTimeout(InspectOptions{}))
+	assert.Equal(t, int32(5000), inspectVisibilityTimeout(InspectOptions{MaxMessages: 100000}))
+	assert.Equal(t, int32(10), inspectVisibilityTimeout(InspectOptions{MaxMessages: 100000, VisibilityTimeout: 10}))
+}
+
+func TestInspectReleasesMessages(t *testing.T) {
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: `{"EventType":"TestMessage"}`},
+		fakeMessage{MessageId: "m2", Body: `{"EventType":"TestMessage"}`},
+		fakeMessage{MessageId: "m3", Body: `{"EventType":"TestMessage"}`},
+	).WithRedelivery()
+
+	manager := newFakeSQSManager(queue)
+
+	report, err := manager.Inspect(context.Background(), InspectOptions{QueueUrl: "https://sqs.eu-west-2.amazonaws.com/123456789012/DLQ", MaxMessages: 2})
+	fmt.Println(report)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Total)
+
+	// the uninspected message is released when the scan stops, and the inspected messages after it
+	assert.Equal(t, []string{"m3-handle", "m1-handle", "m2-handle"}, queue.Released())
+	assert.Empty(t, queue.Deleted())
+
+	// a redrive straight after the inspection finds every message
+	summary, err := manager.Redrive(context.Background(), RedriveOptions{
+		SourceUrl: "https://sqs.eu-west-2.amazonaws.com/123456789012/DLQ",
+		TargetUrl: "https://sqs.eu-west-2.amazonaws.com/123456789012/Queue",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, RedriveSummary{Received: 3, Moved: 3}, summary)
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (w failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func TestInspectReleasesMessagesOnError(t *testing.T) {
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: `{"EventType":"TestMessage"}`},
+		fakeMessage{MessageId: "m2", Body: `{"EventType":"TestMessage"}`},
+	)
+
+	_, err := newFakeSQSManager(queue).Inspect(context.Background(), InspectOptions{QueueUrl: "https://sqs.eu-west-2.amazonaws.com/123456789012/DLQ", Dump: failingWriter{}})
+	assert.NotNil(t, err)
+
+	assert.Equal(t, []string{"m2-handle", "m1-handle"}, queue.Released())
+}
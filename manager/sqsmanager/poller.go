@@ -0,0 +1,173 @@
+package sqsmanager
+
+// https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/sqs-short-and-long-polling.html
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/bruno-beloff-aviva/event-core/lambda/handler/singleshot"
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+const (
+	pollWaitTimeSeconds = 20
+	pollMaxMessages     = 10
+	pollErrorBackoff    = time.Second
+)
+
+// MessageDecoder converts a received message to the event type of the gateway.
+type MessageDecoder[T any] func(message types.Message) (T, error)
+
+// Poller long-polls a queue for workers that do not run as Lambdas, passing each message to the same
+// SingleshotGateway as a Lambda handler would. A message is deleted only when it has been processed - otherwise it
+// becomes visible again, and is eventually moved to the DLQ.
+type Poller[T any] struct {
+	manager           SQSManager
+	gateway           singleshot.SingleshotGateway[T]
+	decode            MessageDecoder[T]
+	QueueUrl          string
+	Concurrency       int
+	VisibilityTimeout int32
+}
+
+// NewPoller - visibilityTimeout is in seconds, and is extended while a message is being processed.
+func NewPoller[T any](manager SQSManager, gateway singleshot.SingleshotGateway[T], decode MessageDecoder[T], queueUrl string, concurrency int, visibilityTimeout int32) Poller[T] {
+	return Poller[T]{
+		manager:           manager,
+		gateway:           gateway,
+		decode:            decode,
+		QueueUrl:          queueUrl,
+		Concurrency:       max(concurrency, 1),
+		VisibilityTimeout: max(visibilityTimeout, 2),
+	}
+}
+
+// DecodeJSON decodes the message body, e.g. from an SNS subscription with raw message delivery.
+func DecodeJSON[T any](message types.Message) (T, error) {
+	var event T
+
+	err := json.Unmarshal([]byte(aws.ToString(message.Body)), &event)
+
+	return event, err
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Run polls until ctx is cancelled, then waits for the messages in progress to be processed.
+func (p Poller[T]) Run(ctx context.Context) {
+	p.manager.logger.Info("Poller.Run", zap.String("queueUrl", p.QueueUrl), zap.Int("concurrency", p.Concurrency))
+
+	var wait sync.WaitGroup
+
+	for worker := 0; worker < p.Concurrency; worker++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+			p.poll(ctx)
+		}()
+	}
+
+	wait.Wait()
+
+	p.manager.logger.Info("Poller stopped", zap.String("queueUrl", p.QueueUrl))
+}
+
+func (p Poller[T]) poll(ctx context.Context) {
+	for ctx.Err() == nil {
+		response, err := p.manager.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(p.QueueUrl),
+			MaxNumberOfMessages:         pollMaxMessages,
+			WaitTimeSeconds:             pollWaitTimeSeconds,
+			VisibilityTimeout:           p.VisibilityTimeout,
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAll},
+		})
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			p.manager.logger.Error("ReceiveMessage", zap.String("queueUrl", p.QueueUrl), zap.Error(err))
+			time.Sleep(pollErrorBackoff)
+			continue
+		}
+
+		p.processAll(ctx, response.Messages)
+	}
+}
+
+// processAll processes the messages in turn - every message has a heartbeat from when it is received, so that those
+// waiting their turn do not become visible again. Once a message of a FIFO group fails, the later messages of the
+// group are made visible again, so that they are not processed out of order. When ctx is cancelled, the message in
+// progress is finished, and those waiting are made visible again.
+func (p Poller[T]) processAll(ctx context.Context, messages []types.Message) {
+	stops := make([]func(), len(messages))
+
+	for i, message := range messages {
+		heartbeat := NewVisibilityHeartbeat(p.manager, p.QueueUrl, aws.ToString(message.ReceiptHandle), time.Duration(p.VisibilityTimeout)*time.Second)
+		stops[i] = heartbeat.Start(context.WithoutCancel(ctx))
+	}
+
+	failedGroups := map[string]bool{}
+
+	var released []types.Message
+
+	for i, message := range messages {
+		group := message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+
+		if ctx.Err() != nil || failedGroups[group] {
+			stops[i]()
+			released = append(released, message)
+			continue
+		}
+
+		if !p.process(context.WithoutCancel(ctx), message, stops[i]) && group != "" {
+			failedGroups[group] = true
+		}
+	}
+
+	p.manager.release(context.WithoutCancel(ctx), p.QueueUrl, released)
+}
+
+// process returns true if the message was processed - its heartbeat is stopped before it is deleted, so that the
+// heartbeat cannot extend a deleted message.
+func (p Poller[T]) process(ctx context.Context, message types.Message, stop func()) bool {
+	defer stop()
+
+	ctx = tracing.Extract(ctx, StringAttributes(message))
+	logger := tracing.Logger(ctx, p.manager.logger).With(zap.String("messageId", aws.ToString(message.MessageId)))
+
+	event, err := p.decode(message)
+	if err != nil {
+		logger.Error("Couldn't decode message", zap.Error(err))
+		return false
+	}
+
+	_, err = p.gateway.ProcessOnce(ctx, event)
+	if err != nil {
+		return false
+	}
+
+	stop()
+
+	_, err = p.manager.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(p.QueueUrl),
+		ReceiptHandle: message.ReceiptHandle,
+	})
+
+	if err != nil {
+		logger.Error("DeleteMessage", zap.Error(err))
+	}
+
+	return true
+}
@@ -0,0 +1,161 @@
+package sqsmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bruno-beloff-aviva/event-core/lambda/handler/singleshot"
+	"github.com/bruno-beloff-aviva/event-core/services"
+
+	"github.com/joerdav/zapray"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPollerEvent struct {
+	ID   string
+	Fail bool
+}
+
+// testPollerHandler records when each event started processing.
+type testPollerHandler struct {
+	mutex    *sync.Mutex
+	started  map[string]time.Time
+	duration time.Duration
+}
+
+func (h testPollerHandler) UniqueID(event testPollerEvent) (string, string, error) {
+	return "policy1", event.ID, nil
+}
+
+func (h testPollerHandler) Process(ctx context.Context, event testPollerEvent) error {
+	h.mutex.Lock()
+	h.started[event.ID] = time.Now()
+	h.mutex.Unlock()
+
+	time.Sleep(h.duration)
+
+	if event.Fail {
+		return errors.New("failed")
+	}
+
+	return nil
+}
+
+func runTestPoller(t *testing.T, queue fakeSQS, handler testPollerHandler, visibilityTimeout int32, processed int) {
+	gateway := singleshot.NewSingleshotGateway[testPollerEvent](zapray.NewNop(), handler, services.NullEventHasBeenProcessed, services.NullMarkEventAsProcessed)
+	poller := NewPoller(newFakeSQSManager(queue), gateway, DecodeJSON[testPollerEvent], "https://sqs.eu-west-2.amazonaws.com/123456789012/Queue", 1, visibilityTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		for {
+			handler.mutex.Lock()
+			started := len(handler.started)
+			handler.mutex.Unlock()
+
+			if started == processed {
+				cancel()
+				return
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	done := make(chan struct{})
+
+	go func() {
+		poller.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("poller did not stop")
+	}
+}
+
+func newTestPollerHandler(duration time.Duration) testPollerHandler {
+	return testPollerHandler{mutex: &sync.Mutex{}, started: map[string]time.Time{}, duration: duration}
+}
+
+func TestPollerDeletesProcessedMessages(t *testing.T) {
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: `{"ID":"e1"}`},
+		fakeMessage{MessageId: "m2", Body: `{"ID":"e2","Fail":true}`},
+		fakeMessage{MessageId: "m3", Body: `not JSON`},
+		fakeMessage{MessageId: "m4", Body: `{"ID":"e4"}`},
+	)
+
+	runTestPoller(t, queue, newTestPollerHandler(0), 30, 3)
+	fmt.Println(queue.Deleted())
+
+	// the failed and undecodable messages are left to become visible again
+	assert.Equal(t, []string{"m1-handle", "m4-handle"}, queue.Deleted())
+}
+
+func TestPollerHeartbeatsWaitingMessages(t *testing.T) {
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: `{"ID":"e1"}`},
+		fakeMessage{MessageId: "m2", Body: `{"ID":"e2"}`},
+	)
+
+	// the second message waits longer than half the visibility timeout for the first to be processed
+	handler := newTestPollerHandler(1500 * time.Millisecond)
+	runTestPoller(t, queue, handler, 2, 2)
+
+	var extended bool
+
+	for _, change := range queue.VisibilityChanges() {
+		if change.ReceiptHandle == "m2-handle" && change.At.Before(handler.started["e2"]) {
+			assert.Equal(t, int32(2), change.VisibilityTimeout)
+			extended = true
+		}
+	}
+
+	assert.True(t, extended, "m2 was not extended while waiting")
+	assert.Equal(t, []string{"m1-handle", "m2-handle"}, queue.Deleted())
+}
+
+func TestPollerKeepsFIFOGroupOrder(t *testing.T) {
+	group := func(id string) map[string]string {
+		return map[string]string{"MessageGroupId": id}
+	}
+
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: `{"ID":"e1","Fail":true}`, Attributes: group("g1")},
+		fakeMessage{MessageId: "m2", Body: `{"ID":"e2"}`, Attributes: group("g2")},
+		fakeMessage{MessageId: "m3", Body: `{"ID":"e3"}`, Attributes: group("g1")},
+		fakeMessage{MessageId: "m4", Body: `{"ID":"e4"}`, Attributes: group("g2")},
+	)
+
+	handler := newTestPollerHandler(0)
+	runTestPoller(t, queue, handler, 30, 3)
+	fmt.Println(queue.Deleted())
+
+	// the later message of the failed group is not processed, and is made visible again
+	assert.Equal(t, []string{"m2-handle", "m4-handle"}, queue.Deleted())
+	assert.NotContains(t, handler.started, "e3")
+	assert.Equal(t, []string{"m3-handle"}, queue.Released())
+}
+
+func TestPollerReleasesWaitingMessagesOnCancel(t *testing.T) {
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: `{"ID":"e1"}`},
+		fakeMessage{MessageId: "m2", Body: `{"ID":"e2"}`},
+		fakeMessage{MessageId: "m3", Body: `{"ID":"e3"}`},
+	)
+
+	// the poller is cancelled while the first message is in progress
+	handler := newTestPollerHandler(300 * time.Millisecond)
+	runTestPoller(t, queue, handler, 30, 1)
+
+	assert.Equal(t, []string{"m1-handle"}, queue.Deleted())
+	assert.Equal(t, []string{"m2-handle", "m3-handle"}, queue.Released())
+	assert.Equal(t, 1, len(handler.started))
+}
@@ -0,0 +1,398 @@
+package sqsmanager
+
+// https://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/sqs-configure-dead-letter-queue-redrive.html
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+const (
+	RedriveMoved     = "moved"
+	RedriveWouldMove = "would-move"
+	RedriveSkipped   = "skipped"
+	RedriveFailed    = "failed"
+)
+
+// RedriveVisibilityTimeout is the least default visibility timeout of messages that are not moved, in seconds.
+const RedriveVisibilityTimeout = 30
+
+// a conservative rate of moving messages, each sent and deleted in turn, for estimating the duration of a redrive
+const redriveMessagesPerSecond = 10
+
+// MessageFilter selects the messages to be redriven.
+type MessageFilter func(message types.Message) bool
+
+// RedriveOptions - if TargetUrl is empty, messages are moved back to the DLQ's source queue. MaxMessages and
+// MessagesPerSecond are unlimited if zero. Messages that are not moved become visible again after VisibilityTimeout
+// seconds - this must be longer than the redrive, or it stops when the first of them is received again. If zero, it
+// is estimated from the number of messages in the source queue and the rate.
+type RedriveOptions struct {
+	SourceUrl         string
+	TargetUrl         string
+	Filter            MessageFilter
+	MaxMessages       int
+	MessagesPerSecond float64
+	DryRun            bool
+	PreserveGroups    bool
+	VisibilityTimeout int32
+	Report            io.Writer
+}
+
+// RedriveRecord is a line of the JSONL report.
+type RedriveRecord struct {
+	MessageId       string
+	Action          string
+	TargetMessageId string `json:",omitempty"`
+	MessageGroupId  string `json:",omitempty"`
+	Error           string `json:",omitempty"`
+}
+
+// RedriveSummary - Truncated if the redrive stopped on receiving a message again, before the source was empty.
+type RedriveSummary struct {
+	Received  int
+	Moved     int
+	Skipped   int
+	Failed    int
+	Truncated bool
+}
+
+// ParseFilter parses terms of the form body~REGEX, body=TEXT, attr.NAME~REGEX or attr.NAME=VALUE - a message is
+// selected if it matches all the terms.
+func ParseFilter(terms []string) (MessageFilter, error) {
+	var matchers []MessageFilter
+
+	for _, term := range terms {
+		matcher, err := parseTerm(term)
+		if err != nil {
+			return nil, err
+		}
+
+		matchers = append(matchers, matcher)
+	}
+
+	return func(message types.Message) bool {
+		for _, matcher := range matchers {
+			if !matcher(message) {
+				return false
+			}
+		}
+
+		return true
+	}, nil
+}
+
+func parseTerm(term string) (MessageFilter, error) {
+	index := strings.IndexAny(term, "~=")
+	if index < 0 {
+		return nil, fmt.Errorf("filter term %q has no operator", term)
+	}
+
+	field, operator, operand := term[:index], term[index], term[index+1:]
+
+	var value func(message types.Message) (string, bool)
+
+	switch {
+	case field == "body":
+		value = func(message types.Message) (string, bool) {
+			return aws.ToString(message.Body), true
+		}
+
+	case strings.HasPrefix(field, "attr.") && len(field) > len("attr."):
+		name := strings.TrimPrefix(field, "attr.")
+
+		value = func(message types.Message) (string, bool) {
+			attribute, ok := message.MessageAttributes[name]
+			return aws.ToString(attribute.StringValue), ok
+		}
+
+	default:
+		return nil, fmt.Errorf("filter term %q has unknown field %q", term, field)
+	}
+
+	if operator == '=' {
+		return func(message types.Message) bool {
+			v, ok := value(message)
+			return ok && v == operand
+		}, nil
+	}
+
+	pattern, err := regexp.Compile(operand)
+	if err != nil {
+		return nil, fmt.Errorf("filter term %q: %w", term, err)
+	}
+
+	return func(message types.Message) bool {
+		v, ok := value(message)
+		return ok && pattern.MatchString(v)
+	}, nil
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// DeadLetterSourceQueue returns the URL of the only queue that uses the DLQ.
+func (m SQSManager) DeadLetterSourceQueue(ctx context.Context, dlqUrl string) (string, error) {
+	response, err := m.sqsClient.ListDeadLetterSourceQueues(ctx, &sqs.ListDeadLetterSourceQueuesInput{QueueUrl: aws.String(dlqUrl)})
+	if err != nil {
+		return "", err
+	}
+
+	if len(response.QueueUrls) != 1 {
+		return "", fmt.Errorf("%s is the DLQ of %d queues - give the target", dlqUrl, len(response.QueueUrls))
+	}
+
+	return response.QueueUrls[0], nil
+}
+
+// Redrive moves the messages selected by the filter from the source queue to the target, sending each message before
+// deleting it. Messages that are not selected become visible again after the visibility timeout. The redrive stops
+// when the source is empty, when a message is received again - when the summary is Truncated - or when MaxMessages
+// have been moved. The messages received with the last but not redriven are made visible at once.
+func (m SQSManager) Redrive(ctx context.Context, options RedriveOptions) (RedriveSummary, error) {
+	var summary RedriveSummary
+
+	if options.TargetUrl == "" {
+		targetUrl, err := m.DeadLetterSourceQueue(ctx, options.SourceUrl)
+		if err != nil {
+			return summary, err
+		}
+
+		options.TargetUrl = targetUrl
+	}
+
+	m.logger.Info("Redrive", zap.String("sourceUrl", options.SourceUrl), zap.String("targetUrl", options.TargetUrl), zap.Bool("dryRun", options.DryRun))
+
+	var report *json.Encoder
+	if options.Report != nil {
+		report = json.NewEncoder(options.Report)
+	}
+
+	var interval time.Duration
+	if options.MessagesPerSecond > 0 {
+		interval = time.Duration(float64(time.Second) / options.MessagesPerSecond)
+	}
+
+	messages := 0
+
+	if options.VisibilityTimeout == 0 {
+		count, err := m.approximateMessages(ctx, options.SourceUrl)
+		if err != nil {
+			return summary, err
+		}
+
+		messages = count
+		if options.MaxMessages > 0 && options.Filter == nil {
+			messages = min(messages, options.MaxMessages)
+		}
+	}
+
+	next := time.Now()
+
+	truncated, err := m.receiveAll(ctx, options.SourceUrl, redriveVisibilityTimeout(options, messages), func(message types.Message) (bool, error) {
+		if options.MaxMessages > 0 && summary.Moved >= options.MaxMessages {
+			return false, nil
+		}
+
+		summary.Received++
+
+		if interval > 0 {
+			select {
+			case <-ctx.Done():
+				return false, ctx.Err()
+			case <-time.After(time.Until(next)):
+			}
+
+			next = time.Now().Add(interval)
+		}
+
+		record := m.redriveMessage(ctx, options, message)
+
+		switch record.Action {
+		case RedriveMoved, RedriveWouldMove:
+			summary.Moved++
+		case RedriveSkipped:
+			summary.Skipped++
+		case RedriveFailed:
+			summary.Failed++
+		}
+
+		if report != nil {
+			return true, report.Encode(record)
+		}
+
+		return true, nil
+	})
+
+	summary.Truncated = truncated
+
+	return summary, err
+}
+
+func redriveVisibilityTimeout(options RedriveOptions, messages int) int32 {
+	if options.VisibilityTimeout > 0 {
+		return options.VisibilityTimeout
+	}
+
+	rate := float64(redriveMessagesPerSecond)
+	if options.MessagesPerSecond > 0 {
+		rate = min(rate, options.MessagesPerSecond)
+	}
+
+	return int32(min(max(RedriveVisibilityTimeout, int(float64(messages)/rate)), maxVisibilityTimeout))
+}
+
+func (m SQSManager) approximateMessages(ctx context.Context, queueUrl string) (int, error) {
+	response, err := m.sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueUrl),
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(response.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)])
+}
+
+// receiveAll passes each message received from the queue to each, until the queue is empty, a message is received
+// again, or each returns false - it returns true if it stopped on a message received again. Messages that are not
+// deleted become visible again after the visibility timeout, except those received but not passed to each when it
+// stops, which are made visible at once.
+func (m SQSManager) receiveAll(ctx context.Context, queueUrl string, visibilityTimeout int32, each func(message types.Message) (bool, error)) (bool, error) {
+	seen := map[string]bool{}
+
+	for {
+		response, err := m.sqsClient.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:                    aws.String(queueUrl),
+			MaxNumberOfMessages:         pollMaxMessages,
+			WaitTimeSeconds:             1,
+			VisibilityTimeout:           visibilityTimeout,
+			MessageAttributeNames:       []string{"All"},
+			MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameAll},
+		})
+
+		if err != nil {
+			return false, err
+		}
+
+		if len(response.Messages) == 0 {
+			return false, nil
+		}
+
+		for n, message := range response.Messages {
+			messageId := aws.ToString(message.MessageId)
+
+			if seen[messageId] {
+				m.logger.Warn("receiveAll: message received again - stopping", zap.String("queueUrl", queueUrl), zap.String("messageId", messageId))
+				m.release(ctx, queueUrl, response.Messages[n:])
+				return true, nil
+			}
+
+			seen[messageId] = true
+
+			more, err := each(message)
+			if err != nil {
+				m.release(ctx, queueUrl, response.Messages[n+1:])
+				return false, err
+			}
+
+			if !more {
+				m.release(ctx, queueUrl, response.Messages[n:])
+				return false, nil
+			}
+		}
+	}
+}
+
+// release makes the messages visible again, ten at a time - failures are logged, as the messages become visible
+// anyway when their visibility timeout expires. The messages are released even if ctx is cancelled.
+func (m SQSManager) release(ctx context.Context, queueUrl string, messages []types.Message) {
+	ctx = context.WithoutCancel(ctx)
+
+	for start := 0; start < len(messages); start += pollMaxMessages {
+		chunk := messages[start:min(start+pollMaxMessages, len(messages))]
+		entries := make([]types.ChangeMessageVisibilityBatchRequestEntry, len(chunk))
+
+		for i, message := range chunk {
+			entries[i] = types.ChangeMessageVisibilityBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				ReceiptHandle:     message.ReceiptHandle,
+				VisibilityTimeout: 0,
+			}
+		}
+
+		response, err := m.sqsClient.ChangeMessageVisibilityBatch(ctx, &sqs.ChangeMessageVisibilityBatchInput{
+			QueueUrl: aws.String(queueUrl),
+			Entries:  entries,
+		})
+
+		if err != nil {
+			m.logger.Error("ChangeMessageVisibilityBatch", zap.String("queueUrl", queueUrl), zap.Error(err))
+			continue
+		}
+
+		for _, failed := range response.Failed {
+			m.logger.Error("ChangeMessageVisibilityBatch", zap.String("queueUrl", queueUrl), zap.String("code", aws.ToString(failed.Code)), zap.String("message", aws.ToString(failed.Message)))
+		}
+	}
+}
+
+func (m SQSManager) redriveMessage(ctx context.Context, options RedriveOptions, message types.Message) RedriveRecord {
+	record := RedriveRecord{MessageId: aws.ToString(message.MessageId), Action: RedriveSkipped}
+
+	if options.Filter != nil && !options.Filter(message) {
+		return record
+	}
+
+	var messageOptions MessageOptions
+
+	if options.PreserveGroups {
+		record.MessageGroupId = message.Attributes[string(types.MessageSystemAttributeNameMessageGroupId)]
+
+		if record.MessageGroupId != "" {
+			messageOptions.MessageGroupId = record.MessageGroupId
+			messageOptions.MessageDeduplicationId = record.MessageId
+		}
+	}
+
+	if options.DryRun {
+		record.Action = RedriveWouldMove
+		return record
+	}
+
+	messageOptions.Attributes = message.MessageAttributes
+
+	result, err := m.Send(ctx, options.TargetUrl, aws.ToString(message.Body), messageOptions)
+	if err != nil {
+		record.Action = RedriveFailed
+		record.Error = err.Error()
+
+		return record
+	}
+
+	record.TargetMessageId = result.MessageId
+
+	_, err = m.sqsClient.DeleteMessage(ctx, &sqs.DeleteMessageInput{QueueUrl: aws.String(options.SourceUrl), ReceiptHandle: message.ReceiptHandle})
+	if err != nil {
+		// the message has been sent, so may be redriven twice
+		record.Action = RedriveFailed
+		record.Error = err.Error()
+
+		return record
+	}
+
+	record.Action = RedriveMoved
+
+	return record
+}
@@ -0,0 +1,107 @@
+package sqsmanager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFilter(t *testing.T) {
+	message := types.Message{
+		Body:              aws.String(`{"EventType":"TestMessage","Path":"/quote/123"}`),
+		MessageAttributes: map[string]types.MessageAttributeValue{"EventType": StringAttribute("TestMessage")},
+	}
+
+	filter, err := ParseFilter([]string{`body~"/quote/\d+"`, "attr.EventType=TestMessage"})
+	assert.Nil(t, err)
+	assert.True(t, filter(message))
+
+	filter, err = ParseFilter([]string{"attr.EventType~^Policy"})
+	assert.Nil(t, err)
+	assert.False(t, filter(message))
+
+	filter, err = ParseFilter([]string{"attr.Missing=x"})
+	assert.Nil(t, err)
+	assert.False(t, filter(message))
+
+	filter, err = ParseFilter(nil)
+	assert.Nil(t, err)
+	assert.True(t, filter(message))
+
+	_, err = ParseFilter([]string{"header=x"})
+	assert.NotNil(t, err)
+
+	_, err = ParseFilter([]string{"body~("})
+	assert.NotNil(t, err)
+}
+
+func TestRedriveReleasesUnredrivenMessages(t *testing.T) {
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: "1"},
+		fakeMessage{MessageId: "m2", Body: "2"},
+		fakeMessage{MessageId: "m3", Body: "3"},
+		fakeMessage{MessageId: "m4", Body: "4"},
+	)
+
+	options := RedriveOptions{
+		SourceUrl:   "https://sqs.eu-west-2.amazonaws.com/123456789012/DLQ",
+		TargetUrl:   "https://sqs.eu-west-2.amazonaws.com/123456789012/Queue",
+		MaxMessages: 2,
+		DryRun:      true,
+	}
+
+	summary, err := newFakeSQSManager(queue).Redrive(context.Background(), options)
+	fmt.Println(summary)
+
+	assert.Nil(t, err)
+	assert.Equal(t, RedriveSummary{Received: 2, Moved: 2}, summary)
+
+	var released []string
+
+	for _, change := range queue.VisibilityChanges() {
+		assert.Equal(t, int32(0), change.VisibilityTimeout)
+		released = append(released, change.ReceiptHandle)
+	}
+
+	assert.Equal(t, []string{"m3-handle", "m4-handle"}, released)
+}
+
+func TestRedriveVisibilityTimeout(t *testing.T) {
+	assert.Equal(t, int32(RedriveVisibilityTimeout), redriveVisibilityTimeout(RedriveOptions{}, 100))
+	assert.Equal(t, int32(1000), redriveVisibilityTimeout(RedriveOptions{}, 10000))
+	assert.Equal(t, int32(5000), redriveVisibilityTimeout(RedriveOptions{MessagesPerSecond: 2}, 10000))
+	assert.Equal(t, int32(maxVisibilityTimeout), redriveVisibilityTimeout(RedriveOptions{MessagesPerSecond: 0.1}, 10000))
+	assert.Equal(t, int32(10), redriveVisibilityTimeout(RedriveOptions{VisibilityTimeout: 10}, 10000))
+}
+
+func TestRedriveTruncatedBySkippedMessages(t *testing.T) {
+	queue := newFakeSQS(
+		fakeMessage{MessageId: "m1", Body: "move"},
+		fakeMessage{MessageId: "m2", Body: "skip"},
+		fakeMessage{MessageId: "m3", Body: "move"},
+		fakeMessage{MessageId: "m4", Body: "skip"},
+	).WithRedelivery()
+
+	filter, err := ParseFilter([]string{"body=move"})
+	assert.Nil(t, err)
+
+	options := RedriveOptions{
+		SourceUrl: "https://sqs.eu-west-2.amazonaws.com/123456789012/DLQ",
+		TargetUrl: "https://sqs.eu-west-2.amazonaws.com/123456789012/Queue",
+		Filter:    filter,
+	}
+
+	// the skipped messages become visible again during the redrive, as if the visibility timeout were too short
+	summary, err := newFakeSQSManager(queue).Redrive(context.Background(), options)
+	fmt.Println(summary)
+
+	assert.Nil(t, err)
+	assert.Equal(t, RedriveSummary{Received: 4, Moved: 2, Skipped: 2, Truncated: true}, summary)
+	assert.Equal(t, []fakeSent{{QueueUrl: options.TargetUrl, MessageBody: "move"}, {QueueUrl: options.TargetUrl, MessageBody: "move"}}, queue.Sent())
+	assert.Equal(t, []string{"m1-handle", "m3-handle"}, queue.Deleted())
+	assert.Equal(t, []string{"m2-handle", "m4-handle"}, queue.Released())
+}
@@ -0,0 +1,126 @@
+package sqsmanager
+
+// https://docs.aws.amazon.com/AWSSimpleQueueService/latest/APIReference/API_SendMessageBatch.html
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/batch"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.uber.org/zap"
+)
+
+type BatchEntry struct {
+	Message string
+	Options MessageOptions
+}
+
+// BatchResult is the result for the entry with the same index - Err is nil if the message was sent.
+type BatchResult struct {
+	SendResult
+	Err error
+}
+
+// BatchEntryError is a failure reported for a single entry - entries that are not the sender's fault are retried.
+type BatchEntryError = batch.EntryError
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// SendBatch sends the entries in batches of at most ten entries and 256 KB, retrying throttled and failed entries
+// that are not the sender's fault, with backoff - see batch.Retry for the ordering of FIFO message groups. The error
+// is non-nil if any entry could not be sent.
+func (m SQSManager) SendBatch(ctx context.Context, queueUrl string, entries []BatchEntry) ([]BatchResult, error) {
+	m.logger.Debug("SendBatch", zap.String("queueUrl", queueUrl), zap.Int("entries", len(entries)))
+
+	results := make([]BatchResult, len(entries))
+
+	send := func(ctx context.Context, chunk []int) map[int]error {
+		return m.sendChunk(ctx, queueUrl, entries, chunk, results)
+	}
+
+	group := func(entry BatchEntry) string {
+		return entry.Options.MessageGroupId
+	}
+
+	errs, err := batch.Retry(ctx, entries, entrySize, group, send)
+
+	for i := range results {
+		results[i].Err = errs[i]
+	}
+
+	if err != nil {
+		return results, err
+	}
+
+	if failed := batch.Failed(errs); failed > 0 {
+		m.logger.Error("Couldn't send messages", zap.String("queueUrl", queueUrl), zap.Int("failed", failed))
+		return results, fmt.Errorf("%d of %d messages could not be sent", failed, len(entries))
+	}
+
+	return results, nil
+}
+
+// sendChunk records the results of the chunk's sent entries, returning the errors of the others.
+func (m SQSManager) sendChunk(ctx context.Context, queueUrl string, entries []BatchEntry, chunk []int, results []BatchResult) map[int]error {
+	requestEntries := make([]types.SendMessageBatchRequestEntry, len(chunk))
+
+	for n, i := range chunk {
+		options := entries[i].Options
+
+		requestEntries[n] = types.SendMessageBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			MessageBody:       aws.String(entries[i].Message),
+			DelaySeconds:      options.DelaySeconds,
+			MessageAttributes: options.Attributes,
+		}
+
+		if options.MessageGroupId != "" {
+			requestEntries[n].MessageGroupId = aws.String(options.MessageGroupId)
+		}
+
+		if options.MessageDeduplicationId != "" {
+			requestEntries[n].MessageDeduplicationId = aws.String(options.MessageDeduplicationId)
+		}
+	}
+
+	errs := map[int]error{}
+
+	response, err := m.sqsClient.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{QueueUrl: aws.String(queueUrl), Entries: requestEntries})
+	if err != nil {
+		m.logger.Error("SendMessageBatch", zap.String("queueUrl", queueUrl), zap.Error(err))
+
+		for _, i := range chunk {
+			errs[i] = err
+		}
+
+		return errs
+	}
+
+	for _, successful := range response.Successful {
+		i, _ := strconv.Atoi(aws.ToString(successful.Id))
+		results[i].SendResult = SendResult{MessageId: aws.ToString(successful.MessageId), SequenceNumber: aws.ToString(successful.SequenceNumber)}
+	}
+
+	for _, failed := range response.Failed {
+		i, _ := strconv.Atoi(aws.ToString(failed.Id))
+		errs[i] = BatchEntryError{Code: aws.ToString(failed.Code), Message: aws.ToString(failed.Message), Retryable: !failed.SenderFault}
+	}
+
+	return errs
+}
+
+// entrySize counts the body and the attributes, as SQS does.
+func entrySize(entry BatchEntry) int {
+	size := len(entry.Message)
+
+	for name, attribute := range entry.Options.Attributes {
+		size += len(name) + len(aws.ToString(attribute.DataType)) + len(aws.ToString(attribute.StringValue)) + len(attribute.BinaryValue)
+	}
+
+	return size
+}
@@ -1,14 +1,35 @@
 package sqsmanager
 
+// https://docs.aws.amazon.com/AWSSimpleQueueService/latest/APIReference/API_SendMessage.html
+
 import (
 	"context"
+	"strconv"
+
+	"github.com/bruno-beloff-aviva/event-core/manager/tracing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/joerdav/zapray"
 	"go.uber.org/zap"
 )
 
+// MessageOptions are all optional - FIFO queues require a MessageGroupId, and a MessageDeduplicationId unless the
+// queue has content-based deduplication.
+type MessageOptions struct {
+	MessageGroupId         string
+	MessageDeduplicationId string
+	DelaySeconds           int32
+	Attributes             map[string]types.MessageAttributeValue
+}
+
+// SequenceNumber is only given by FIFO queues.
+type SendResult struct {
+	MessageId      string
+	SequenceNumber string
+}
+
 type SQSManager struct {
 	logger    *zapray.Logger
 	sqsClient *sqs.Client
@@ -20,13 +41,67 @@ func NewSQSManager(logger *zapray.Logger, cfg aws.Config) SQSManager {
 	return SQSManager{logger: logger, sqsClient: sqsClient}
 }
 
+func StringAttribute(value string) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+}
+
+func NumberAttribute(value float64) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("Number"), StringValue: aws.String(strconv.FormatFloat(value, 'f', -1, 64))}
+}
+
+func BinaryAttribute(value []byte) types.MessageAttributeValue {
+	return types.MessageAttributeValue{DataType: aws.String("Binary"), BinaryValue: value}
+}
+
+// StringAttributes returns the string message attributes of a received message, with the AWSTraceHeader system
+// attribute, as carriers of the trace context.
+func StringAttributes(message types.Message) map[string]string {
+	attributes := make(map[string]string, len(message.MessageAttributes)+1)
+
+	if traceHeader, ok := message.Attributes[tracing.AWSTraceHeader]; ok {
+		attributes[tracing.AWSTraceHeader] = traceHeader
+	}
+
+	for name, attribute := range message.MessageAttributes {
+		if attribute.StringValue != nil {
+			attributes[name] = *attribute.StringValue
+		}
+	}
+
+	return attributes
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
 func (m SQSManager) Pub(ctx context.Context, queueUrl string, message string) error {
-	m.logger.Debug("Pub", zap.String("queueUrl", queueUrl))
+	_, err := m.Send(ctx, queueUrl, message, MessageOptions{})
+
+	return err
+}
+
+func (m SQSManager) Send(ctx context.Context, queueUrl string, message string, options MessageOptions) (SendResult, error) {
+	m.logger.Debug("Send", zap.String("queueUrl", queueUrl), zap.String("messageGroupId", options.MessageGroupId))
+
+	sendInput := sqs.SendMessageInput{
+		MessageBody:       aws.String(message),
+		QueueUrl:          aws.String(queueUrl),
+		DelaySeconds:      options.DelaySeconds,
+		MessageAttributes: options.Attributes,
+	}
+
+	if options.MessageGroupId != "" {
+		sendInput.MessageGroupId = aws.String(options.MessageGroupId)
+	}
+
+	if options.MessageDeduplicationId != "" {
+		sendInput.MessageDeduplicationId = aws.String(options.MessageDeduplicationId)
+	}
 
-	m.sqsClient.SendMessage(ctx, &sqs.SendMessageInput{
-		MessageBody: &message,
-		QueueUrl:    &queueUrl,
-	})
+	response, err := m.sqsClient.SendMessage(ctx, &sendInput)
+	if err != nil {
+		m.logger.Error("Couldn't send message", zap.String("queueUrl", queueUrl), zap.Error(err))
+		return SendResult{}, err
+	}
 
-	return nil
+	return SendResult{MessageId: aws.ToString(response.MessageId), SequenceNumber: aws.ToString(response.SequenceNumber)}, nil
 }
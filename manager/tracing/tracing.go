@@ -0,0 +1,193 @@
+package tracing
+
+// https://docs.aws.amazon.com/xray/latest/devguide/xray-concepts.html#xray-concepts-tracingheader
+// https://www.w3.org/TR/trace-context/#traceparent-header
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-xray-sdk-go/header"
+	"github.com/aws/aws-xray-sdk-go/xray"
+	"github.com/joerdav/zapray"
+	"go.uber.org/zap"
+)
+
+// message attributes, injected by publishers and extracted by consumers
+const (
+	TraceHeaderAttribute   = "X-Amzn-Trace-Id"
+	TraceParentAttribute   = "traceparent"
+	CorrelationIDAttribute = "CorrelationId"
+)
+
+// AWSTraceHeader is the SQS system attribute set by services with active tracing, such as SNS.
+const AWSTraceHeader = "AWSTraceHeader"
+
+// TraceContext follows a request across hops - any field may be empty.
+type TraceContext struct {
+	TraceHeader   string
+	TraceParent   string
+	CorrelationID string
+}
+
+type contextKey struct{}
+
+// WithTraceContext returns a context carrying the trace context.
+func WithTraceContext(ctx context.Context, trace TraceContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, trace)
+}
+
+// FromContext returns the trace context set by WithTraceContext or Extract - otherwise the X-Ray trace of the current
+// segment or Lambda invocation, if any.
+func FromContext(ctx context.Context) TraceContext {
+	trace, _ := ctx.Value(contextKey{}).(TraceContext)
+
+	if trace.TraceHeader == "" {
+		trace.TraceHeader = xrayTraceHeader(ctx)
+	}
+
+	if trace.TraceParent == "" {
+		trace.TraceParent = TraceParentFromHeader(trace.TraceHeader)
+	}
+
+	return trace
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Inject returns the message attributes for the trace context of ctx.
+func Inject(ctx context.Context) map[string]string {
+	trace := FromContext(ctx)
+	attributes := map[string]string{}
+
+	if trace.TraceHeader != "" {
+		attributes[TraceHeaderAttribute] = trace.TraceHeader
+	}
+
+	if trace.TraceParent != "" {
+		attributes[TraceParentAttribute] = trace.TraceParent
+	}
+
+	if trace.CorrelationID != "" {
+		attributes[CorrelationIDAttribute] = trace.CorrelationID
+	}
+
+	return attributes
+}
+
+// Extract returns a context carrying the trace context of the message attributes - if the message has no trace header
+// attribute, the AWSTraceHeader system attribute is used, if given.
+func Extract(ctx context.Context, attributes map[string]string) context.Context {
+	trace := TraceContext{
+		TraceHeader:   attributes[TraceHeaderAttribute],
+		TraceParent:   attributes[TraceParentAttribute],
+		CorrelationID: attributes[CorrelationIDAttribute],
+	}
+
+	if trace.TraceHeader == "" {
+		trace.TraceHeader = attributes[AWSTraceHeader]
+	}
+
+	if trace.TraceHeader == "" {
+		trace.TraceHeader = TraceHeaderFromParent(trace.TraceParent)
+	}
+
+	if trace.TraceParent == "" {
+		trace.TraceParent = TraceParentFromHeader(trace.TraceHeader)
+	}
+
+	return WithTraceContext(ctx, trace)
+}
+
+// Logger returns the logger with the trace context of ctx - the X-Ray fields match those of zapray Logger.Trace.
+func Logger(ctx context.Context, logger *zapray.Logger) *zapray.Logger {
+	trace := FromContext(ctx)
+
+	var fields []zap.Field
+
+	if trace.TraceHeader != "" {
+		traceHeader := header.FromString(trace.TraceHeader)
+		fields = append(fields, zap.String("@xrayTraceId", traceHeader.TraceID), zap.String("@xraySegmentId", traceHeader.ParentID))
+	}
+
+	if trace.TraceParent != "" {
+		fields = append(fields, zap.String("traceparent", trace.TraceParent))
+	}
+
+	if trace.CorrelationID != "" {
+		fields = append(fields, zap.String("correlationId", trace.CorrelationID))
+	}
+
+	if len(fields) == 0 {
+		return logger
+	}
+
+	return logger.With(fields...)
+}
+
+// ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// TraceParentFromHeader converts Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1 to
+// 00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01 - it returns "" if the header has no parent.
+func TraceParentFromHeader(traceHeader string) string {
+	if traceHeader == "" {
+		return ""
+	}
+
+	h := header.FromString(traceHeader)
+
+	fields := strings.Split(h.TraceID, "-")
+	if len(fields) != 3 || fields[0] != "1" || len(h.ParentID) != 16 {
+		return ""
+	}
+
+	flags := "00"
+	if h.SamplingDecision == header.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s%s-%s-%s", fields[1], fields[2], h.ParentID, flags)
+}
+
+// TraceHeaderFromParent is the inverse of TraceParentFromHeader - it returns "" if traceParent is not valid.
+func TraceHeaderFromParent(traceParent string) string {
+	fields := strings.Split(traceParent, "-")
+	if len(fields) != 4 || len(fields[1]) != 32 || len(fields[2]) != 16 {
+		return ""
+	}
+
+	sampled := header.NotSampled
+	if fields[3] == "01" {
+		sampled = header.Sampled
+	}
+
+	h := header.Header{
+		TraceID:          fmt.Sprintf("1-%s-%s", fields[1][:8], fields[1][8:]),
+		ParentID:         fields[2],
+		SamplingDecision: sampled,
+	}
+
+	return h.String()
+}
+
+// xrayTraceHeader returns the header of the current segment, or of the Lambda invocation.
+func xrayTraceHeader(ctx context.Context) string {
+	if segment := xray.GetSegment(ctx); segment != nil {
+		sampled := header.NotSampled
+		if segment.Sampled {
+			sampled = header.Sampled
+		}
+
+		h := header.Header{TraceID: segment.TraceID, ParentID: segment.ID, SamplingDecision: sampled}
+
+		return h.String()
+	}
+
+	if traceHeader, ok := ctx.Value(xray.LambdaTraceHeaderKey).(string); ok {
+		return traceHeader
+	}
+
+	return os.Getenv("_X_AMZN_TRACE_ID")
+}
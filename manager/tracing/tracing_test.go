@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testTraceHeader = "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+	testTraceParent = "00-5759e988bd862e3fe1be46a994272793-53995c3f42cd8ad8-01"
+)
+
+func TestTraceParent(t *testing.T) {
+	traceParent := TraceParentFromHeader(testTraceHeader)
+	fmt.Println(traceParent)
+
+	assert.Equal(t, testTraceParent, traceParent)
+	assert.Equal(t, testTraceHeader, TraceHeaderFromParent(traceParent))
+
+	assert.Equal(t, "", TraceParentFromHeader("Root=1-5759e988-bd862e3fe1be46a994272793"))
+	assert.Equal(t, "", TraceHeaderFromParent("invalid"))
+}
+
+func TestInjectExtract(t *testing.T) {
+	ctx := WithTraceContext(context.Background(), TraceContext{TraceHeader: testTraceHeader, CorrelationID: "C1"})
+
+	attributes := Inject(ctx)
+	assert.Equal(t, testTraceParent, attributes[TraceParentAttribute])
+
+	extracted := FromContext(Extract(context.Background(), attributes))
+	assert.Equal(t, TraceContext{TraceHeader: testTraceHeader, TraceParent: testTraceParent, CorrelationID: "C1"}, extracted)
+
+	extracted = FromContext(Extract(context.Background(), map[string]string{AWSTraceHeader: testTraceHeader}))
+	assert.Equal(t, testTraceParent, extracted.TraceParent)
+}
@@ -9,6 +9,8 @@ type TestMessage struct {
 	Sent   string
 	Path   string
 	Client string
+	// omitted if empty, as the key of the sparse testreception.PolicyIndex
+	PolicyOrQuoteID string `json:",omitempty" dynamodbav:",omitempty"`
 }
 
 func NewTestMessage(client string, path string) TestMessage {
@@ -12,6 +12,9 @@ import (
 
 var DeletionKeys = []string{"PK", "Received"}
 
+var SubscriberIndex = dbmanager.GlobalIndex("SubscriberIndex", dbmanager.StringAttribute("Subscriber"), DynamoSortKey())
+var PolicyIndex = dbmanager.GlobalIndex("PolicyIndex", dbmanager.StringAttribute("PolicyOrQuoteID"), DynamoSortKey())
+
 type TestReception struct {
 	testmessage.TestMessage
 	PK         string
@@ -27,6 +30,14 @@ func DynamoSortKey() *awsdynamodb.Attribute {
 	return dbmanager.StringAttribute("Received")
 }
 
+func DynamoIndex() dbmanager.Index {
+	return dbmanager.PrimaryIndex(DynamoPartitionKey(), DynamoSortKey())
+}
+
+func DynamoSecondaryIndexes() []dbmanager.Index {
+	return []dbmanager.Index{SubscriberIndex, PolicyIndex}
+}
+
 func NewTestReception(subscriber string, message testmessage.TestMessage) TestReception {
 	now := time.Now().UTC().Format(time.RFC3339Nano)
 	pk := message.Sent + "/" + subscriber
@@ -1,13 +1,16 @@
 package testreception
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
 	"time"
 
+	"github.com/bruno-beloff-aviva/event-core/manager/dbmanager"
 	"github.com/bruno-beloff-aviva/event-core/service/testmessage"
 
+	"github.com/joerdav/zapray"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -51,3 +54,38 @@ func TestNewReceptionJSON(t *testing.T) {
 	assert.Equal(t, reception.Client, "client")
 	assert.Equal(t, reception.Path, "path")
 }
+
+func TestSubscriberIndex(t *testing.T) {
+	assert.Equal(t, "SubscriberIndex", SubscriberIndex.IndexName)
+	assert.Equal(t, "Subscriber", SubscriberIndex.PartitionKeyName())
+	assert.Equal(t, "Received", SubscriberIndex.SortKeyName())
+	assert.Equal(t, "PK", DynamoIndex().PartitionKeyName())
+}
+
+func TestPolicyIndexQuery(t *testing.T) {
+	ctx := context.Background()
+	manager := dbmanager.NewMemoryDynamoManager(zapray.NewNop(), DynamoIndex(), "")
+
+	policyMessage := testmessage.NewTestMessage("client", "path")
+	policyMessage.PolicyOrQuoteID = "POL-1"
+
+	first := NewTestReception("sub1", policyMessage)
+	second := NewTestReception("sub2", policyMessage)
+	other := NewTestReception("sub1", testmessage.NewTestMessage("client", "path"))
+
+	for _, reception := range []TestReception{first, second, other} {
+		err := manager.Put(ctx, &reception)
+		assert.Nil(t, err)
+	}
+
+	var receptions []TestReception
+	err := manager.Query(ctx, PolicyIndex, dbmanager.KeyCondition{PartitionValue: "POL-1"}, &receptions)
+	fmt.Println(receptions)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(receptions))
+
+	for _, reception := range receptions {
+		assert.Equal(t, "POL-1", reception.PolicyOrQuoteID)
+	}
+}